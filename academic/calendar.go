@@ -0,0 +1,112 @@
+// Package academic holds the academic calendar: which days are holidays and
+// which weekend days are make-up workdays (调休). Calendars are imported from
+// ICS or from a small YAML file.
+package academic
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DayKind classifies a special day.
+type DayKind string
+
+const (
+	Holiday DayKind = "holiday"
+	Makeup  DayKind = "makeup"
+)
+
+// Day is one labelled calendar day.
+type Day struct {
+	Date string  `json:"date"` // YYYY-MM-DD
+	Kind DayKind `json:"kind"`
+	Name string  `json:"name,omitempty"`
+}
+
+// Label is a short Chinese description, e.g. "国庆节（放假）".
+func (d Day) Label() string {
+	name := d.Name
+	switch d.Kind {
+	case Holiday:
+		if name == "" {
+			name = "节假日"
+		}
+		return name + "（放假）"
+	case Makeup:
+		if name == "" {
+			name = "调休"
+		}
+		return name + "（上班）"
+	}
+	return name
+}
+
+// Calendar maps YYYY-MM-DD to its label.
+type Calendar struct {
+	Name string         `json:"name,omitempty"`
+	Days map[string]Day `json:"days"`
+}
+
+// New returns an empty calendar.
+func New(name string) *Calendar {
+	return &Calendar{Name: name, Days: map[string]Day{}}
+}
+
+// Lookup returns the label of day, if it is special.
+func (c *Calendar) Lookup(day time.Time) (Day, bool) {
+	if c == nil {
+		return Day{}, false
+	}
+	d, ok := c.Days[day.Format("2006-01-02")]
+	return d, ok
+}
+
+// Between lists special days from..to inclusive, ordered by date.
+func (c *Calendar) Between(from, to time.Time) []Day {
+	var out []Day
+	if c == nil {
+		return out
+	}
+	lo, hi := from.Format("2006-01-02"), to.Format("2006-01-02")
+	for date, d := range c.Days {
+		if date >= lo && date <= hi {
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date < out[j].Date })
+	return out
+}
+
+// Merge adds other's days, overriding existing dates.
+func (c *Calendar) Merge(other *Calendar) {
+	for date, d := range other.Days {
+		c.Days[date] = d
+	}
+	if c.Name == "" {
+		c.Name = other.Name
+	}
+}
+
+// addRange labels every day from start to end inclusive.
+func (c *Calendar) addRange(start, end time.Time, d Day) {
+	for t := start; !t.After(end); t = t.AddDate(0, 0, 1) {
+		d.Date = t.Format("2006-01-02")
+		c.Days[d.Date] = d
+	}
+}
+
+// Parse detects ICS or YAML from the content (or format: "ics"/"yaml").
+func Parse(data []byte, format string, loc *time.Location) (*Calendar, error) {
+	switch strings.ToLower(format) {
+	case "ics", "ical", "text/calendar":
+		return ParseICS(bytes.NewReader(data), loc)
+	case "yaml", "yml":
+		return ParseYAML(bytes.NewReader(data), loc)
+	}
+	if bytes.Contains(data[:min(len(data), 512)], []byte("BEGIN:VCALENDAR")) {
+		return ParseICS(bytes.NewReader(data), loc)
+	}
+	return ParseYAML(bytes.NewReader(data), loc)
+}
@@ -0,0 +1,84 @@
+package academic
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"LoginTest/ical"
+)
+
+// ParseICS reads holidays and make-up days from an iCalendar file. A VEVENT
+// is classified by, in order:
+//   - X-DAY-TYPE: HOLIDAY or MAKEUP
+//   - CATEGORIES containing HOLIDAY/假期 or MAKEUP/WORKDAY/补班
+//   - a SUMMARY ending in a separate 休 (holiday) or 班 (make-up) mark, as
+//     used by the common Chinese public-holiday feeds: "国庆节 休",
+//     "国庆节（班）"; a bare suffix is not enough ("午休", "值班")
+//   - a SUMMARY containing 放假 (holiday), or 调休/补班 (make-up)
+//
+// Other events are ignored.
+func ParseICS(r io.Reader, loc *time.Location) (*Calendar, error) {
+	cal, err := ical.Parse(r, loc)
+	if err != nil {
+		return nil, err
+	}
+	out := New(cal.Name)
+	for _, ev := range cal.Events {
+		kind, name := classify(ev)
+		if kind == "" {
+			continue
+		}
+		d := Day{Kind: kind, Name: name}
+		start := ev.Start.In(loc)
+		start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+		end := start
+		if ev.AllDay && ev.End.After(ev.Start) {
+			// DTEND of an all-day event is exclusive.
+			end = ev.End.In(loc).AddDate(0, 0, -1)
+		}
+		out.addRange(start, end, d)
+	}
+	return out, nil
+}
+
+func classify(ev ical.Event) (DayKind, string) {
+	name := strings.TrimSpace(ev.Summary)
+	if p, ok := ev.Prop("X-DAY-TYPE"); ok {
+		switch strings.ToUpper(strings.TrimSpace(p.Value)) {
+		case "HOLIDAY":
+			return Holiday, name
+		case "MAKEUP":
+			return Makeup, name
+		}
+	}
+	for _, c := range ev.Categories {
+		switch strings.ToUpper(c) {
+		case "HOLIDAY", "假期", "放假":
+			return Holiday, name
+		case "MAKEUP", "WORKDAY", "补班", "调休上班":
+			return Makeup, name
+		}
+	}
+	for _, m := range dayMarks {
+		if strings.HasSuffix(name, m.mark) {
+			return m.kind, strings.TrimSpace(strings.TrimSuffix(name, m.mark))
+		}
+	}
+	switch {
+	case strings.Contains(name, "放假"):
+		return Holiday, strings.TrimSpace(strings.TrimSuffix(name, "放假"))
+	case strings.Contains(name, "调休"), strings.Contains(name, "补班"):
+		return Makeup, name
+	}
+	return "", ""
+}
+
+// dayMarks are the explicit SUMMARY suffixes of holiday feeds.
+var dayMarks = []struct {
+	mark string
+	kind DayKind
+}{
+	{" 休", Holiday}, {"(休)", Holiday}, {"（休）", Holiday},
+	{" 班", Makeup}, {"(班)", Makeup}, {"（班）", Makeup},
+}
@@ -0,0 +1,142 @@
+package academic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ParseYAML reads the simple calendar format below. Only this subset of
+// YAML is understood: top-level scalars and lists of flat mappings.
+//
+//	name: 2026-2027 学年秋季学期
+//	holidays:
+//	  - date: 2026-10-01
+//	    end: 2026-10-07        # optional, inclusive
+//	    name: 国庆节
+//	makeup:
+//	  - date: 2026-10-10
+//	    name: 国庆调休
+func ParseYAML(r io.Reader, loc *time.Location) (*Calendar, error) {
+	out := New("")
+	var (
+		section string
+		item    map[string]string
+		itemAt  int
+	)
+	flush := func() error {
+		if item == nil {
+			return nil
+		}
+		defer func() { item = nil }()
+		return out.addYAMLItem(section, item, itemAt, loc)
+	}
+
+	sc := bufio.NewScanner(r)
+	n := 0
+	for sc.Scan() {
+		n++
+		line := stripComment(sc.Text())
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		text := strings.TrimSpace(line)
+
+		if indent == 0 {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			key, val, ok := strings.Cut(text, ":")
+			if !ok {
+				return nil, fmt.Errorf("yaml: line %d: expected key: value", n)
+			}
+			key, val = strings.TrimSpace(key), unquote(val)
+			switch key {
+			case "name":
+				out.Name = val
+			case "holidays", "makeup", "makeups", "workdays":
+				section = key
+				if val != "" && val != "[]" {
+					return nil, fmt.Errorf("yaml: line %d: %s must be a list", n, key)
+				}
+			default:
+				return nil, fmt.Errorf("yaml: line %d: unknown key %q", n, key)
+			}
+			continue
+		}
+
+		if section == "" {
+			return nil, fmt.Errorf("yaml: line %d: unexpected indentation", n)
+		}
+		if strings.HasPrefix(text, "- ") || text == "-" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			item, itemAt = map[string]string{}, n
+			text = strings.TrimSpace(strings.TrimPrefix(text, "-"))
+			if text == "" {
+				continue
+			}
+		}
+		if item == nil {
+			return nil, fmt.Errorf("yaml: line %d: expected a list item", n)
+		}
+		key, val, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("yaml: line %d: expected key: value", n)
+		}
+		item[strings.TrimSpace(key)] = unquote(val)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Calendar) addYAMLItem(section string, item map[string]string, line int, loc *time.Location) error {
+	start, err := time.ParseInLocation("2006-01-02", item["date"], loc)
+	if err != nil {
+		return fmt.Errorf("yaml: item at line %d: invalid date %q", line, item["date"])
+	}
+	end := start
+	if v := item["end"]; v != "" {
+		if end, err = time.ParseInLocation("2006-01-02", v, loc); err != nil || end.Before(start) {
+			return fmt.Errorf("yaml: item at line %d: invalid end %q", line, v)
+		}
+	}
+	d := Day{Kind: Holiday, Name: item["name"]}
+	if section != "holidays" {
+		d.Kind = Makeup
+	}
+	c.addRange(start, end, d)
+	return nil
+}
+
+func stripComment(line string) string {
+	inQuote := rune(0)
+	for i, r := range line {
+		switch {
+		case inQuote != 0 && r == inQuote:
+			inQuote = 0
+		case inQuote == 0 && (r == '"' || r == '\''):
+			inQuote = r
+		case inQuote == 0 && r == '#' && (i == 0 || line[i-1] == ' '):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && (v[0] == '"' && v[len(v)-1] == '"' || v[0] == '\'' && v[len(v)-1] == '\'') {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"LoginTest/academic"
+)
+
+// ------------------------------
+// Academic calendar (holidays and 调休)
+// ------------------------------
+// The calendar labels holidays and make-up workdays. It is the union of the
+// file named by ACADEMIC_CALENDAR (ICS or YAML, read at startup) and
+// whatever an operator imported through POST /admin/calendar, which is kept
+// in state/academic_calendar.json and wins on conflicting dates.
+
+const (
+	academicCalendarFile = "academic_calendar.json"
+	maxCalendarUpload    = 2 << 20
+)
+
+var (
+	baseCalendar     = academic.New("")
+	importedCalendar = academic.New("")
+	calendarMu       sync.RWMutex
+)
+
+// loadAcademicCalendar reads ACADEMIC_CALENDAR and the imported calendar.
+func loadAcademicCalendar() {
+	calendarMu.Lock()
+	defer calendarMu.Unlock()
+	if path := os.Getenv("ACADEMIC_CALENDAR"); path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			var cal *academic.Calendar
+			if cal, err = academic.Parse(data, calendarFormat(path), academicLoc); err == nil {
+				baseCalendar = cal
+			}
+		}
+		if err != nil {
+			log.Printf("load ACADEMIC_CALENDAR %s failed: %v", path, err)
+		}
+	}
+	if err := loadDataFile(academicCalendarFile, importedCalendar); err != nil {
+		log.Printf("load academic calendar failed: %v", err)
+	}
+	if importedCalendar.Days == nil {
+		importedCalendar.Days = map[string]academic.Day{}
+	}
+}
+
+// calendarFormat guesses the format from a file name; "" lets Parse sniff.
+func calendarFormat(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".ics"):
+		return "ics"
+	case strings.HasSuffix(name, ".yaml"), strings.HasSuffix(name, ".yml"):
+		return "yaml"
+	}
+	return ""
+}
+
+// academicCalendar returns the merged calendar.
+func academicCalendar() *academic.Calendar {
+	calendarMu.RLock()
+	defer calendarMu.RUnlock()
+	cal := academic.New(importedCalendar.Name)
+	cal.Merge(baseCalendar)
+	cal.Merge(importedCalendar)
+	return cal
+}
+
+// calendarDay returns the label of a date (YYYYMMDD), if it is special.
+func calendarDay(dateStr string) (*academic.Day, bool) {
+	day, err := time.ParseInLocation("20060102", dateStr, academicLoc)
+	if err != nil {
+		return nil, false
+	}
+	calendarMu.RLock()
+	defer calendarMu.RUnlock()
+	d, ok := importedCalendar.Lookup(day)
+	if !ok {
+		d, ok = baseCalendar.Lookup(day)
+	}
+	if !ok {
+		return nil, false
+	}
+	return &d, true
+}
+
+// annotateCalendar adds a "calendar" label to a course payload of one day.
+func annotateCalendar(payload map[string]any, dateStr string) {
+	if d, ok := calendarDay(dateStr); ok {
+		payload["calendar"] = calendarView{Day: *d, Label: d.Label()}
+	}
+}
+
+// calendarView is a calendar day as served to clients.
+type calendarView struct {
+	academic.Day
+	Label string `json:"label"`
+}
+
+// handleCalendar lists holidays and make-up days.
+// GET /calendar?from=<date expr>&to=<date expr> (default: the current semester, or 90 days)
+func handleCalendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	from, err := resolveDate(q.Get("from"), clock())
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to := from.AddDate(0, 0, 90)
+	if s, ok := semesterFor(from); ok && q.Get("from") == "" && s.contains(from) {
+		from, _ = s.startDate()
+		to = s.End()
+	}
+	if v := strings.TrimSpace(q.Get("to")); v != "" {
+		if to, err = resolveDate(v, clock()); err != nil {
+			http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "to is before from", http.StatusBadRequest)
+		return
+	}
+	cal := academicCalendar()
+	days := []calendarView{}
+	for _, d := range cal.Between(from, to) {
+		days = append(days, calendarView{Day: d, Label: d.Label()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"name": cal.Name,
+		"from": from.Format("20060102"),
+		"to":   to.Format("20060102"),
+		"days": days,
+	})
+}
+
+// handleAdminCalendar imports or clears the academic calendar.
+// POST   body: ICS or YAML (?format=ics|yaml, otherwise detected); ?replace=1 drops earlier imports -> { imported, days }
+// GET    -> the imported calendar
+// DELETE -> 204, forgets imported days (ACADEMIC_CALENDAR stays)
+func handleAdminCalendar(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		calendarMu.RLock()
+		b, err := json.Marshal(importedCalendar)
+		calendarMu.RUnlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+
+	case http.MethodPost:
+		data, err := io.ReadAll(io.LimitReader(r.Body, maxCalendarUpload+1))
+		if err != nil {
+			http.Error(w, "read body failed", http.StatusBadRequest)
+			return
+		}
+		if len(data) > maxCalendarUpload {
+			http.Error(w, "calendar too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "text/calendar") {
+			format = "ics"
+		}
+		cal, err := academic.Parse(data, format, academicLoc)
+		if err != nil {
+			http.Error(w, "invalid calendar: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(cal.Days) == 0 {
+			http.Error(w, "calendar contains no holidays or make-up days", http.StatusBadRequest)
+			return
+		}
+		calendarMu.Lock()
+		if r.URL.Query().Get("replace") == "1" {
+			importedCalendar = academic.New(cal.Name)
+		}
+		importedCalendar.Merge(cal)
+		if cal.Name != "" {
+			importedCalendar.Name = cal.Name
+		}
+		total := len(importedCalendar.Days)
+		err = saveDataFile(academicCalendarFile, importedCalendar)
+		calendarMu.Unlock()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("save calendar failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"imported": len(cal.Days),
+			"days":     total,
+		})
+
+	case http.MethodDelete:
+		calendarMu.Lock()
+		importedCalendar = academic.New("")
+		err := saveDataFile(academicCalendarFile, importedCalendar)
+		calendarMu.Unlock()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("save calendar failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
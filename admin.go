@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ------------------------------
+// Admin access
+// ------------------------------
+// Operator endpoints live under /admin/ and require
+// "Authorization: Bearer <ADMIN_TOKEN>". Without ADMIN_TOKEN they are
+// disabled: behind a reverse proxy every request comes from loopback, so
+// the peer address proves nothing.
+
+// requireAdmin writes an error and returns false when r is not allowed.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" {
+		http.Error(w, "admin endpoints are disabled, set ADMIN_TOKEN", http.StatusForbidden)
+		return false
+	}
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// handleDebugVars serves the expvar metrics (upstream drift and legacy
+// route counters) to admins only.
+func handleDebugVars(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	expvar.Handler().ServeHTTP(w, r)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ------------------------------
+// Versioned API
+// ------------------------------
+// Every JSON and export endpoint lives under /api/v1/. The paths used
+// before it existed stay registered as aliases, mostly of the very same
+// handler, and mark their responses as deprecated:
+//
+//	Deprecation: @<unix time of apiV1Since>         (RFC 9745)
+//	Link: </api/v1/...>; rel="successor-version"
+//	Sunset: <HTTP date>                              (RFC 8594, endpoints being retired)
+//
+// Each alias hit is counted on /debug/vars (admin only, see admin.go), so
+// removal can be planned from real traffic:
+//
+//	legacy_requests   "<path>" -> count
+//	legacy_last_used  "<path>" -> RFC 3339 time of the last hit
+//
+// /ui/ and /web/ are pages rather than API and are not versioned.
+
+const apiV1 = "/api/v1"
+
+// apiV1Since is when the /api/v1 paths replaced the old ones.
+var apiV1Since = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+// legacySunset is when retiring endpoints stop answering; LEGACY_SUNSET
+// (YYYY-MM-DD) moves it.
+var legacySunset = apiV1Since.AddDate(0, 6, 0)
+
+var (
+	legacyRequests = expvar.NewMap("legacy_requests")
+	legacyLastUsed = expvar.NewMap("legacy_last_used")
+)
+
+// apiRoutes are the /api/v1 endpoints, relative to apiV1, with the path
+// each one had before.
+var apiRoutes = []struct {
+	Path, Legacy string
+	Handler      http.HandlerFunc
+}{
+	{"/login", "/login", handleLogin},
+	{"/logout", "/logout", handleLogout},
+	{"/me", "/me", handleMe},
+	{"/sign-in", "/api/sign-in", handleSignIn},
+	{"/courses/today", "/courses/today", handleCoursesToday},
+	{"/courses/changes", "/courses/changes", handleCourseChanges},
+	{"/courses/range", "/courses/range", handleCoursesRange},
+	{"/courses/week", "/courses/week", handleCoursesWeek},
+	{"/courses/week.pdf", "/courses/week.pdf", handleCoursesWeekPDF},
+	{"/courses/week.png", "/courses/week.png", handleCoursesWeekPNG},
+	{"/courses/day.png", "/courses/day.png", handleCoursesDayPNG},
+	{"/courses/calendar.ics", "/courses/calendar.ics", handleCalendarExport},
+	{"/courses/map.geojson", "/courses/map.geojson", handleCoursesMap},
+	{"/courses/now", "/courses/now", handleCoursesNow},
+	{"/schedule/conflicts", "/schedule/conflicts", handleScheduleConflicts},
+	{"/semesters", "/semesters", handleSemesters},
+	{"/calendar", "/calendar", handleCalendar},
+	{"/events", "/events", handleEvents},
+	{"/events/personal", "/events/personal", handlePersonalEvents},
+	{"/events/calendars", "/events/calendars", handleCalendarFeeds},
+	{"/events/calendars/refresh", "/events/calendars/refresh", handleCalendarFeedRefresh},
+	{"/notifications", "/notifications", handleNotifications},
+	{"/notifications/digest", "/notifications/digest", handleDigest},
+	{"/notifications/digest/send", "/notifications/digest/send", handleDigestSend},
+	{"/admin/upstream-schema", "/admin/upstream-schema", handleUpstreamSchema},
+	{"/admin/calendar", "/admin/calendar", handleAdminCalendar},
+}
+
+// legacyRoutes are old endpoints with a response shape of their own; they
+// keep their handler and are being retired in favour of Successor.
+var legacyRoutes = []struct {
+	Path, Successor string
+	Handler         http.HandlerFunc
+}{
+	{"/get_courses", "/courses/today", handleGetCourses},        // { STATUS, delta, result }
+	{"/getTodayCourse", "/courses/today", handleGetTodayCourse}, // no session, fixed upstream sessionId
+}
+
+// loadAPIConfig reads LEGACY_SUNSET.
+func loadAPIConfig() {
+	if v := os.Getenv("LEGACY_SUNSET"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			log.Printf("ignoring invalid LEGACY_SUNSET: %v", err)
+			return
+		}
+		legacySunset = t
+	}
+}
+
+// registerAPI adds the /api/v1 routes and their legacy aliases to mux.
+func registerAPI(mux *http.ServeMux) {
+	for _, rt := range apiRoutes {
+		mux.HandleFunc(apiV1+rt.Path, rt.Handler)
+		if rt.Legacy != "" {
+			mux.Handle(rt.Legacy, deprecated(rt.Legacy, rt.Path, rt.Handler, false))
+		}
+	}
+	for _, rt := range legacyRoutes {
+		mux.Handle(rt.Path, deprecated(rt.Path, rt.Successor, rt.Handler, true))
+	}
+}
+
+// deprecated wraps the handler of a legacy path with the deprecation
+// headers and usage counters.
+func deprecated(path, successor string, h http.HandlerFunc, retire bool) http.Handler {
+	since := "@" + strconv.FormatInt(apiV1Since.Unix(), 10)
+	link := "<" + apiV1 + successor + `>; rel="successor-version"`
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		legacyRequests.Add(path, 1)
+		last := new(expvar.String)
+		last.Set(clock().UTC().Format(time.RFC3339))
+		legacyLastUsed.Set(path, last)
+
+		hd := w.Header()
+		hd.Set("Deprecation", since)
+		hd.Add("Link", link)
+		if retire {
+			hd.Set("Sunset", legacySunset.UTC().Format(http.TimeFormat))
+		}
+		h(w, r)
+	})
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"LoginTest/web"
+)
+
+// ------------------------------
+// Embedded web assets
+// ------------------------------
+// /web/ serves the front end from the binary (package web), so the server
+// works from any working directory. Every file also answers under a
+// content-hashed name (main.css -> main.1a2b3c4d5e.css) that is cached for
+// a year; index.html refers to those names, and the plain names are served
+// with "no-cache" so browsers revalidate them by ETag. Text files are
+// gzipped once at startup (or taken from an embedded .gz next to them).
+// Brotli is not provided: the standard library has no brotli encoder and
+// no .br files ship with the front end. WEB_DIR=web serves a directory
+// instead, re-read on every request, for front-end development.
+
+const (
+	assetHashLen   = 10
+	immutableCache = "public, max-age=31536000, immutable"
+)
+
+// asset is one servable file with its encodings.
+type asset struct {
+	Name  string
+	Hash  string
+	Type  string
+	Body  []byte
+	Gzip  []byte // nil when not worth it
+	Mtime time.Time
+}
+
+// assetSet indexes assets by plain and hashed name.
+type assetSet struct {
+	byName map[string]*asset
+	hashed map[string]*asset
+}
+
+var (
+	embeddedAssets *assetSet
+	webDir         string
+)
+
+// loadWebAssets reads WEB_DIR and indexes the embedded files.
+func loadWebAssets() {
+	webDir = os.Getenv("WEB_DIR")
+	if webDir != "" {
+		log.Printf("serving web assets from %s", webDir)
+		return
+	}
+	set, err := buildAssets(web.FS, time.Now())
+	if err != nil {
+		log.Fatalf("load embedded web assets: %v", err)
+	}
+	embeddedAssets = set
+}
+
+// currentAssets is the embedded set, or WEB_DIR read afresh.
+func currentAssets() (*assetSet, error) {
+	if webDir == "" {
+		return embeddedAssets, nil
+	}
+	return buildAssets(os.DirFS(webDir), time.Time{})
+}
+
+// buildAssets indexes fsys. HTML files get their references to other
+// assets ("/web/main.css") rewritten to the hashed names, so they are
+// hashed last.
+func buildAssets(fsys fs.FS, mtime time.Time) (*assetSet, error) {
+	set := &assetSet{byName: map[string]*asset{}, hashed: map[string]*asset{}}
+	variants := map[string][]byte{}
+	var html []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		switch path.Ext(name) {
+		case ".go":
+			return nil
+		case ".gz":
+			variants[name] = b
+			return nil
+		case ".html":
+			html = append(html, name)
+		}
+		set.byName[name] = &asset{Name: name, Body: b, Mtime: mtime}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range html {
+		a := set.byName[name]
+		for _, other := range set.byName {
+			if path.Ext(other.Name) == ".html" {
+				continue
+			}
+			set.hash(other)
+			a.Body = bytes.ReplaceAll(a.Body, []byte(`"/web/`+other.Name+`"`), []byte(`"/web/`+hashedName(other)+`"`))
+		}
+	}
+	for _, a := range set.byName {
+		set.hash(a)
+		if gz, ok := variants[a.Name+".gz"]; ok {
+			a.Gzip = gz
+		} else if compressible(a.Type) {
+			a.Gzip = gzipBytes(a.Body)
+		}
+		set.hashed[hashedName(a)] = a
+	}
+	return set, nil
+}
+
+// hash fills in the hash and content type once.
+func (s *assetSet) hash(a *asset) {
+	if a.Hash != "" {
+		return
+	}
+	sum := sha256.Sum256(a.Body)
+	a.Hash = hex.EncodeToString(sum[:])[:assetHashLen]
+	a.Type = mime.TypeByExtension(path.Ext(a.Name))
+	if a.Type == "" {
+		a.Type = http.DetectContentType(a.Body)
+	}
+}
+
+// hashedName inserts the hash before the extension: main.css -> main.<hash>.css.
+func hashedName(a *asset) string {
+	ext := path.Ext(a.Name)
+	return strings.TrimSuffix(a.Name, ext) + "." + a.Hash + ext
+}
+
+func compressible(ctype string) bool {
+	ctype, _, _ = strings.Cut(ctype, ";")
+	return strings.HasPrefix(ctype, "text/") || strings.HasSuffix(ctype, "javascript") ||
+		strings.HasSuffix(ctype, "json") || strings.HasSuffix(ctype, "+xml") || ctype == "image/svg+xml"
+}
+
+// gzipBytes compresses b, or returns nil when that saves less than a tenth.
+func gzipBytes(b []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if _, err := zw.Write(b); err != nil {
+		return nil
+	}
+	if err := zw.Close(); err != nil || buf.Len() > len(b)*9/10 {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header allows enc
+// (a q of 0 refuses it; "*" covers encodings not named).
+func acceptsEncoding(header, enc string) bool {
+	star := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case enc:
+			return q > 0
+		case "*":
+			star = q > 0
+		}
+	}
+	return star
+}
+
+// handleWebAsset serves /web/<name> and /web/<hashed name>.
+func handleWebAsset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	set, err := currentAssets()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("load web assets: %v", err), http.StatusInternalServerError)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/web/")
+	if name == "" || strings.HasSuffix(name, "/") {
+		name += "index.html"
+	}
+	// Hashed names never change content, except under WEB_DIR.
+	cache := "no-cache"
+	a, ok := set.hashed[name]
+	if ok && webDir == "" {
+		cache = immutableCache
+	} else if !ok {
+		if a, ok = set.byName[name]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	body, etag := a.Body, a.Hash
+	h := w.Header()
+	h.Set("Vary", "Accept-Encoding")
+	accept := r.Header.Get("Accept-Encoding")
+	if a.Gzip != nil && acceptsEncoding(accept, "gzip") {
+		body, etag = a.Gzip, a.Hash+"-gzip"
+		h.Set("Content-Encoding", "gzip")
+	}
+	h.Set("Content-Type", a.Type)
+	h.Set("Cache-Control", cache)
+	h.Set("ETag", `"`+etag+`"`)
+	http.ServeContent(w, r, "", a.Mtime, bytes.NewReader(body))
+}
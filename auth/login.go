@@ -28,6 +28,8 @@ type LoginResponse struct {
 	RoleCodes        string   `json:"roleCodes"`
 	RoleNames        string   `json:"roleNames"`
 	Result           UserInfo `json:"result"`
+	// Extra 保存宽松解码时遇到的未知字段
+	Extra map[string]any `json:"-"`
 }
 
 // UserInfo 表示返回中的用户信息详情
@@ -56,6 +58,8 @@ type UserInfo struct {
 	CloudIP        string `json:"cloudIp"`
 	CloudFlag      string `json:"cloudFlag"`
 	StudentNo      string `json:"studentNo"`
+	// Extra 保存宽松解码时遇到的未知字段
+	Extra map[string]any `json:"-"`
 }
 
 type TodayCourseParams struct {
@@ -0,0 +1,323 @@
+// Package bitfont loads bitmap fonts (BDF, or GNU Unifont's .hex format)
+// and draws them onto images at integer scales. Bitmap fonts such as
+// Unifont or WenQuanYi Unibit cover all of CJK in a few megabytes and need
+// no rasteriser, which keeps image rendering in the standard library.
+package bitfont
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Glyph is one character bitmap. Rows are packed MSB first, Stride bytes
+// per row; XOff/YOff place the bitmap's bottom left relative to the pen
+// position on the baseline, as in BDF.
+type Glyph struct {
+	Advance    int
+	W, H       int
+	XOff, YOff int
+	Stride     int
+	Bits       []byte
+}
+
+func (g *Glyph) set(x, y int) bool {
+	return g.Bits[y*g.Stride+x/8]&(0x80>>(x%8)) != 0
+}
+
+// Font is a set of glyphs sharing a line height.
+type Font struct {
+	Name   string
+	Height int // ascent + descent
+	Ascent int
+	glyphs map[rune]*Glyph
+}
+
+// Glyphs returns how many characters the font covers.
+func (f *Font) Glyphs() int { return len(f.glyphs) }
+
+// Has reports whether the font has a glyph for r.
+func (f *Font) Has(r rune) bool {
+	_, ok := f.glyphs[r]
+	return ok
+}
+
+// Open loads a BDF or .hex font; ".gz" files are decompressed first.
+func Open(path string) (*Font, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	var r io.Reader = fh
+	name := strings.TrimSuffix(path, ".gz")
+	if name != path {
+		zr, err := gzip.NewReader(fh)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var f *Font
+	switch {
+	case strings.HasSuffix(name, ".hex"):
+		f, err = ParseHex(r)
+	case strings.HasSuffix(name, ".bdf"):
+		f, err = ParseBDF(r)
+	default:
+		return nil, fmt.Errorf("%s: unknown font format (want .bdf or .hex)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// ParseBDF reads a Glyph Bitmap Distribution Format font.
+func ParseBDF(r io.Reader) (*Font, error) {
+	f := &Font{glyphs: map[rune]*Glyph{}}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var (
+		g        *Glyph
+		enc      = -1
+		inBitmap bool
+		row      int
+		descent  int
+		boundsH  int
+	)
+	atoi := func(fields []string, i int) int {
+		if i >= len(fields) {
+			return 0
+		}
+		n, _ := strconv.Atoi(fields[i])
+		return n
+	}
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if inBitmap {
+			if fields[0] == "ENDCHAR" {
+				if enc >= 0 {
+					f.glyphs[rune(enc)] = g
+				}
+				g, inBitmap = nil, false
+				continue
+			}
+			if row < g.H {
+				line := fields[0]
+				for i := 0; i < g.Stride && 2*i+2 <= len(line); i++ {
+					b, err := strconv.ParseUint(line[2*i:2*i+2], 16, 8)
+					if err != nil {
+						return nil, fmt.Errorf("bad bitmap row %q", line)
+					}
+					g.Bits[row*g.Stride+i] = byte(b)
+				}
+				row++
+			}
+			continue
+		}
+		switch fields[0] {
+		case "FONT":
+			f.Name = strings.Join(fields[1:], " ")
+		case "FONTBOUNDINGBOX":
+			boundsH = atoi(fields, 2)
+			descent = -atoi(fields, 4)
+		case "FONT_ASCENT":
+			f.Ascent = atoi(fields, 1)
+		case "FONT_DESCENT":
+			descent = atoi(fields, 1)
+		case "STARTCHAR":
+			g, enc = &Glyph{}, -1
+		case "ENCODING":
+			enc = atoi(fields, 1)
+		case "DWIDTH":
+			if g != nil {
+				g.Advance = atoi(fields, 1)
+			}
+		case "BBX":
+			if g != nil {
+				g.W, g.H, g.XOff, g.YOff = atoi(fields, 1), atoi(fields, 2), atoi(fields, 3), atoi(fields, 4)
+			}
+		case "BITMAP":
+			if g == nil {
+				return nil, fmt.Errorf("BITMAP outside STARTCHAR")
+			}
+			g.Stride = (g.W + 7) / 8
+			g.Bits = make([]byte, g.Stride*g.H)
+			inBitmap, row = true, 0
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(f.glyphs) == 0 {
+		return nil, fmt.Errorf("no glyphs")
+	}
+	if f.Ascent == 0 {
+		f.Ascent = boundsH - descent
+	}
+	f.Height = f.Ascent + descent
+	return f, nil
+}
+
+// ParseHex reads GNU Unifont's .hex format: "4E00:<32 or 64 hex digits>"
+// per line, 8 or 16 pixels wide and 16 high.
+func ParseHex(r io.Reader) (*Font, error) {
+	f := &Font{Name: "unifont", Height: 16, Ascent: 14, glyphs: map[rune]*Glyph{}}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		code, bits, ok := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if !ok {
+			continue
+		}
+		cp, err := strconv.ParseUint(code, 16, 32)
+		if err != nil || (len(bits) != 32 && len(bits) != 64) {
+			continue
+		}
+		w := len(bits) / 4 // 32 digits: 8 wide, 64 digits: 16 wide
+		g := &Glyph{Advance: w, W: w, H: 16, YOff: -2, Stride: w / 8, Bits: make([]byte, len(bits)/2)}
+		for i := range g.Bits {
+			b, err := strconv.ParseUint(bits[2*i:2*i+2], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("bad glyph %s", code)
+			}
+			g.Bits[i] = byte(b)
+		}
+		f.glyphs[rune(cp)] = g
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(f.glyphs) == 0 {
+		return nil, fmt.Errorf("no glyphs")
+	}
+	return f, nil
+}
+
+// Face draws with a chain of fonts at one integer scale: each character
+// comes from the first font that has it, and characters none of them has
+// are drawn as an empty box.
+type Face struct {
+	Fonts []*Font
+	Scale int
+}
+
+// Height is the line height of the first font, scaled.
+func (fc Face) Height() int {
+	if len(fc.Fonts) == 0 {
+		return 0
+	}
+	return fc.Fonts[0].Height * fc.scale()
+}
+
+// Ascent is the ascent of the first font, scaled.
+func (fc Face) Ascent() int {
+	if len(fc.Fonts) == 0 {
+		return 0
+	}
+	return fc.Fonts[0].Ascent * fc.scale()
+}
+
+func (fc Face) scale() int { return max(fc.Scale, 1) }
+
+func (fc Face) lookup(r rune) *Glyph {
+	for _, f := range fc.Fonts {
+		if g := f.glyphs[r]; g != nil {
+			return g
+		}
+	}
+	return nil
+}
+
+// missingAdvance is the width of the box drawn for a missing glyph: a
+// square for wide characters, half of it otherwise.
+func (fc Face) missingAdvance(wide bool) int {
+	w := fc.Fonts[0].Height
+	if !wide {
+		w /= 2
+	}
+	return w
+}
+
+// Width is the advance width of s in pixels. wide reports which runes
+// should take a full square when they have to be drawn as a box.
+func (fc Face) Width(s string, wide func(rune) bool) int {
+	if len(fc.Fonts) == 0 {
+		return 0
+	}
+	w := 0
+	for _, r := range s {
+		if g := fc.lookup(r); g != nil {
+			w += g.Advance
+		} else {
+			w += fc.missingAdvance(wide(r))
+		}
+	}
+	return w * fc.scale()
+}
+
+// Draw paints s onto dst with the pen starting at x on baseline y.
+func (fc Face) Draw(dst *image.RGBA, x, y int, s string, c color.RGBA, wide func(rune) bool) {
+	if len(fc.Fonts) == 0 {
+		return
+	}
+	sc := fc.scale()
+	for _, r := range s {
+		g := fc.lookup(r)
+		if g == nil {
+			adv := fc.missingAdvance(wide(r))
+			if r != ' ' {
+				h := fc.Fonts[0].Ascent * 3 / 4
+				box := image.Rect(x+sc, y-h*sc, x+(adv-1)*sc, y)
+				outline(dst, box, sc, c)
+			}
+			x += adv * sc
+			continue
+		}
+		top := y - (g.YOff+g.H)*sc
+		left := x + g.XOff*sc
+		for gy := 0; gy < g.H; gy++ {
+			for gx := 0; gx < g.W; gx++ {
+				if !g.set(gx, gy) {
+					continue
+				}
+				for dy := 0; dy < sc; dy++ {
+					for dx := 0; dx < sc; dx++ {
+						px, py := left+gx*sc+dx, top+gy*sc+dy
+						if image.Pt(px, py).In(dst.Rect) {
+							dst.SetRGBA(px, py, c)
+						}
+					}
+				}
+			}
+		}
+		x += g.Advance * sc
+	}
+}
+
+func outline(dst *image.RGBA, r image.Rectangle, w int, c color.RGBA) {
+	for _, e := range []image.Rectangle{
+		{r.Min, image.Pt(r.Max.X, r.Min.Y+w)},
+		{image.Pt(r.Min.X, r.Max.Y-w), r.Max},
+		{r.Min, image.Pt(r.Min.X+w, r.Max.Y)},
+		{image.Pt(r.Max.X-w, r.Min.Y), r.Max},
+	} {
+		for py := e.Min.Y; py < e.Max.Y; py++ {
+			for px := e.Min.X; px < e.Max.X; px++ {
+				if image.Pt(px, py).In(dst.Rect) {
+					dst.SetRGBA(px, py, c)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"LoginTest/events"
+	"LoginTest/models"
+)
+
+// ------------------------------
+// Per-user course cache
+// ------------------------------
+// courseSnapshot keeps the latest parsed timetable of one user for one date,
+// together with the previous one so that consumers can tell what changed.
+// Like the session store this lives in memory only; the pretty-printed copy
+// under data/ is still written by fetchCourses for offline demos.
+
+type courseSnapshot struct {
+	Courses   models.TodayCoursesResponse
+	FetchedAt time.Time
+	Previous  *models.TodayCoursesResponse
+	// EmptySeen is set when a reply without courses arrived for a day
+	// that had some; the snapshot is kept until a second one confirms it.
+	EmptySeen time.Time
+}
+
+var (
+	// uid -> dateStr -> snapshot
+	courseCache   = map[string]map[string]*courseSnapshot{}
+	courseCacheMu sync.RWMutex
+)
+
+// storeCourses records a freshly fetched timetable for uid/dateStr and
+// derives change events against the previous fetch of the same day.
+// Only successful replies (HTTP 200, STATUS "0") are stored: error and
+// expired-session replies decode with an empty result and would otherwise
+// replace a good snapshot and report every course as removed. An empty
+// timetable replacing a non-empty one is only believed the second time in
+// a row.
+func storeCourses(uid, dateStr string, status int, today models.TodayCoursesResponse) {
+	if uid == "" || status != http.StatusOK || today.STATUS != "0" {
+		return
+	}
+	courseCacheMu.Lock()
+	byDate, ok := courseCache[uid]
+	if !ok {
+		byDate = map[string]*courseSnapshot{}
+		courseCache[uid] = byDate
+	}
+	snap := &courseSnapshot{Courses: today, FetchedAt: clock()}
+	if old, ok := byDate[dateStr]; ok {
+		if len(today.Result) == 0 && len(old.Courses.Result) > 0 && old.EmptySeen.IsZero() {
+			old.EmptySeen = clock()
+			courseCacheMu.Unlock()
+			log.Printf("courses %s of %s: empty reply replacing %d course(s), waiting for confirmation", dateStr, uid, len(old.Courses.Result))
+			return
+		}
+		prev := old.Courses
+		snap.Previous = &prev
+	}
+	byDate[dateStr] = snap
+	courseCacheMu.Unlock()
+
+	bus.Publish(events.CoursesFetched{
+		UID:     uid,
+		Date:    dateStr,
+		Total:   len(today.Result),
+		Courses: today.Result,
+	})
+	if snap.Previous != nil {
+		recordChanges(uid, dateStr, *snap.Previous, today)
+	}
+}
+
+// cachedCourses returns the latest cached timetable of uid for dateStr.
+func cachedCourses(uid, dateStr string) (courseSnapshot, bool) {
+	courseCacheMu.RLock()
+	defer courseCacheMu.RUnlock()
+	snap, ok := courseCache[uid][dateStr]
+	if !ok {
+		return courseSnapshot{}, false
+	}
+	return *snap, true
+}
+
+// cachedDays returns the latest snapshot of every cached date of uid.
+func cachedDays(uid string) map[string]courseSnapshot {
+	courseCacheMu.RLock()
+	defer courseCacheMu.RUnlock()
+	out := make(map[string]courseSnapshot, len(courseCache[uid]))
+	for dateStr, snap := range courseCache[uid] {
+		out[dateStr] = *snap
+	}
+	return out
+}
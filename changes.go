@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"LoginTest/events"
+	"LoginTest/models"
+)
+
+// ------------------------------
+// Schedule change detection
+// ------------------------------
+// Every time a timetable is stored for a user/date that was fetched before,
+// the two snapshots are compared and the differences are kept as change
+// events. They are exposed as a JSON list or an Atom feed.
+
+const (
+	changesFile = "changes.json"
+	// Keep at most this many events per user, oldest are dropped first.
+	maxChangesPerUser = 200
+)
+
+// Change event types.
+const (
+	changeAdded   = "course.added"
+	changeRemoved = "course.removed"
+	changeUpdated = "course.changed"
+)
+
+// ChangeEvent describes one difference between two fetches of a day.
+type ChangeEvent struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Date       string    `json:"date"` // YYYYMMDD of the affected day
+	CourseID   string    `json:"courseId"`
+	CourseName string    `json:"courseName"`
+	Field      string    `json:"field,omitempty"` // only for course.changed
+	Old        string    `json:"old,omitempty"`
+	New        string    `json:"new,omitempty"`
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+// Summary is a one-line human readable description of the event.
+func (e ChangeEvent) Summary() string {
+	switch e.Type {
+	case changeAdded:
+		return fmt.Sprintf("新增课程：%s", e.CourseName)
+	case changeRemoved:
+		return fmt.Sprintf("课程取消：%s", e.CourseName)
+	default:
+		return fmt.Sprintf("%s %s 变更：%s → %s", e.CourseName, changeFieldLabel(e.Field), e.Old, e.New)
+	}
+}
+
+var (
+	// uid -> events, oldest first
+	changeEvents   = map[string][]ChangeEvent{}
+	changeEventsMu sync.RWMutex
+)
+
+// loadChanges restores stored change events from state/changes.json.
+func loadChanges() {
+	changeEventsMu.Lock()
+	defer changeEventsMu.Unlock()
+	if err := loadDataFile(changesFile, &changeEvents); err != nil {
+		log.Printf("load changes failed: %v", err)
+	}
+	if changeEvents == nil {
+		changeEvents = map[string][]ChangeEvent{}
+	}
+}
+
+// recordChanges diffs prev against cur and stores the resulting events.
+func recordChanges(uid, dateStr string, prev, cur models.TodayCoursesResponse) []ChangeEvent {
+	diff := diffCourses(dateStr, prev.Result, cur.Result, clock())
+	if len(diff) == 0 {
+		return nil
+	}
+	changeEventsMu.Lock()
+	list := append(changeEvents[uid], diff...)
+	if len(list) > maxChangesPerUser {
+		list = list[len(list)-maxChangesPerUser:]
+	}
+	changeEvents[uid] = list
+	if err := saveDataFile(changesFile, changeEvents); err != nil {
+		log.Printf("save changes failed: %v", err)
+	}
+	changeEventsMu.Unlock()
+	for _, e := range diff {
+		bus.Publish(events.CourseChanged{
+			UID:        uid,
+			ID:         e.ID,
+			ChangeType: e.Type,
+			Date:       e.Date,
+			CourseID:   e.CourseID,
+			CourseName: e.CourseName,
+			Field:      e.Field,
+			Old:        e.Old,
+			New:        e.New,
+		})
+	}
+	return diff
+}
+
+// courseKey identifies a schedule entry across fetches.
+func courseKey(c models.CourseRecord) string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return c.CourseID + "@" + c.ClassBeginTime
+}
+
+// diffCourses compares two course lists of the same day.
+func diffCourses(dateStr string, prev, cur []models.CourseRecord, now time.Time) []ChangeEvent {
+	old := make(map[string]models.CourseRecord, len(prev))
+	for _, c := range prev {
+		old[courseKey(c)] = c
+	}
+	seen := make(map[string]bool, len(cur))
+
+	var out []ChangeEvent
+	add := func(e ChangeEvent) {
+		e.Date = dateStr
+		e.DetectedAt = now
+		e.ID = fmt.Sprintf("%s-%d-%d", dateStr, now.UnixNano(), len(out))
+		out = append(out, e)
+	}
+	for _, c := range cur {
+		key := courseKey(c)
+		seen[key] = true
+		o, ok := old[key]
+		if !ok {
+			add(ChangeEvent{Type: changeAdded, CourseID: c.CourseID, CourseName: c.CourseName, New: c.ClassBeginTime})
+			continue
+		}
+		for _, f := range []struct{ name, old, new string }{
+			{"classroomName", o.ClassroomName, c.ClassroomName},
+			{"classBeginTime", o.ClassBeginTime, c.ClassBeginTime},
+			{"teacherName", o.TeacherName, c.TeacherName},
+		} {
+			if f.old != f.new {
+				add(ChangeEvent{Type: changeUpdated, CourseID: c.CourseID, CourseName: c.CourseName, Field: f.name, Old: f.old, New: f.new})
+			}
+		}
+	}
+	for _, c := range prev {
+		if !seen[courseKey(c)] {
+			add(ChangeEvent{Type: changeRemoved, CourseID: c.CourseID, CourseName: c.CourseName, Old: c.ClassBeginTime})
+		}
+	}
+	return out
+}
+
+func changeFieldLabel(field string) string {
+	switch field {
+	case "classroomName":
+		return "教室"
+	case "classBeginTime":
+		return "上课时间"
+	case "teacherName":
+		return "教师"
+	}
+	return field
+}
+
+// atomFeed / atomEntry are the minimal Atom 1.0 elements we emit.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Summary string `xml:"summary"`
+}
+
+// handleCourseChanges lists change events of the session user.
+// Query: since=RFC3339 (optional), date=YYYYMMDD (optional), format=json|atom
+// The Atom feed is also chosen when Accept asks for application/atom+xml.
+func handleCourseChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+
+	q := r.URL.Query()
+	var since time.Time
+	if v := strings.TrimSpace(q.Get("since")); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	date := strings.TrimSpace(q.Get("date"))
+
+	changeEventsMu.RLock()
+	var list []ChangeEvent
+	for _, e := range changeEvents[sess.UID] {
+		if !since.IsZero() && !e.DetectedAt.After(since) {
+			continue
+		}
+		if date != "" && e.Date != date {
+			continue
+		}
+		list = append(list, e)
+	}
+	changeEventsMu.RUnlock()
+
+	format := strings.ToLower(q.Get("format"))
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "application/atom+xml") {
+		format = "atom"
+	}
+	if format == "atom" {
+		writeChangesAtom(w, sess, list)
+		return
+	}
+	if list == nil {
+		list = []ChangeEvent{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"changes": list,
+	})
+}
+
+// writeChangesAtom renders events newest first as an Atom feed.
+func writeChangesAtom(w http.ResponseWriter, sess *Session, list []ChangeEvent) {
+	feed := atomFeed{
+		ID:      "urn:ucas-course:changes:" + sess.UID,
+		Title:   "课程变更",
+		Author:  "UCASCoureLogin",
+		Updated: clock().UTC().Format(time.RFC3339),
+	}
+	if len(list) > 0 {
+		feed.Updated = list[len(list)-1].DetectedAt.UTC().Format(time.RFC3339)
+	}
+	for i := len(list) - 1; i >= 0; i-- {
+		e := list[i]
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      "urn:ucas-course:change:" + e.ID,
+			Title:   e.Summary(),
+			Updated: e.DetectedAt.UTC().Format(time.RFC3339),
+			Summary: fmt.Sprintf("%s（%s）", e.Summary(), e.Date),
+		})
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		log.Printf("write atom feed error: %v", err)
+	}
+}
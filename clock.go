@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+	_ "time/tzdata" // slim containers ship without zoneinfo
+
+	"LoginTest/models"
+)
+
+// ------------------------------
+// Academic time zone and clock
+// ------------------------------
+// The server may run in UTC, but "today" for a UCAS student is the Beijing
+// calendar day. Every default date, cache key, reminder and digest goes
+// through academicNow, which is clock() converted to academicLoc.
+// ACADEMIC_TZ overrides the zone (default Asia/Shanghai) for that choice
+// and for display; upstream timestamps are Beijing wall-clock time and are
+// always parsed in models.UpstreamLocation. clock can be swapped for a
+// fixed time when testing.
+
+const defaultAcademicTZ = "Asia/Shanghai"
+
+var (
+	clock       = time.Now
+	academicLoc = models.UpstreamLocation
+)
+
+// loadAcademicTZ reads ACADEMIC_TZ.
+func loadAcademicTZ() {
+	name := os.Getenv("ACADEMIC_TZ")
+	if name == "" {
+		name = defaultAcademicTZ
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("ignoring invalid ACADEMIC_TZ %q: %v", name, err)
+		return
+	}
+	academicLoc = loc
+}
+
+// academicNow is the current time in the academic time zone.
+func academicNow() time.Time {
+	return clock().In(academicLoc)
+}
+
+// academicToday is today's date as YYYYMMDD in the academic time zone.
+func academicToday() string {
+	return academicNow().Format("20060102")
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"LoginTest/models"
+)
+
+// lateUTC is 23:30 UTC on Sunday 1 March 2026, already 07:30 on Monday
+// 2 March in Beijing: every "today" must be the 2nd.
+var lateUTC = time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC)
+
+// setClock fixes clock() and the academic zone for one test and clears
+// the in-memory stores the test may fill.
+func setClock(t *testing.T, now time.Time) {
+	t.Helper()
+	oldClock, oldLoc := clock, academicLoc
+	clock = func() time.Time { return now }
+	academicLoc = models.UpstreamLocation
+	courseCache = map[string]map[string]*courseSnapshot{}
+	webhooks = map[string][]*Webhook{}
+	sentReminders = map[string]time.Time{}
+	t.Cleanup(func() {
+		clock, academicLoc = oldClock, oldLoc
+		courseCache = map[string]map[string]*courseSnapshot{}
+		webhooks = map[string][]*Webhook{}
+		sentReminders = map[string]time.Time{}
+	})
+}
+
+// cacheDay puts courses into the cache as if just fetched.
+func cacheDay(uid, dateStr string, courses ...models.CourseRecord) {
+	if courseCache[uid] == nil {
+		courseCache[uid] = map[string]*courseSnapshot{}
+	}
+	courseCache[uid][dateStr] = &courseSnapshot{
+		Courses:   models.TodayCoursesResponse{STATUS: "0", Result: courses},
+		FetchedAt: clock(),
+	}
+}
+
+func course(id, name, begin, end string) models.CourseRecord {
+	return models.CourseRecord{ID: id, CourseID: id, CourseName: name, ClassBeginTime: begin, ClassEndTime: end}
+}
+
+func TestAcademicToday(t *testing.T) {
+	tests := []struct {
+		name string
+		now  time.Time
+		loc  *time.Location
+		want string
+	}{
+		{"late UTC is next day in Beijing", lateUTC, nil, "20260302"},
+		{"just before Beijing midnight", time.Date(2026, 3, 1, 15, 59, 0, 0, time.UTC), nil, "20260301"},
+		{"Beijing midnight", time.Date(2026, 3, 1, 16, 0, 0, 0, time.UTC), nil, "20260302"},
+		{"ACADEMIC_TZ=UTC", lateUTC, time.UTC, "20260301"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setClock(t, tt.now)
+			if tt.loc != nil {
+				academicLoc = tt.loc
+			}
+			if got := academicToday(); got != tt.want {
+				t.Errorf("academicToday() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveDate(t *testing.T) {
+	setClock(t, lateUTC)
+	tests := []struct {
+		expr string
+		want string // YYYY-MM-DD, "" for an error
+	}{
+		{"", "2026-03-02"},
+		{"today", "2026-03-02"},
+		{"明天", "2026-03-03"},
+		{"yesterday", "2026-03-01"},
+		{"mon", "2026-03-02"},
+		{"sun", "2026-03-08"},
+		{"next-mon", "2026-03-09"},
+		{"last-sun", "2026-03-01"},
+		{"2026-03-05", "2026-03-05"},
+		{"20260305", "2026-03-05"},
+		{"someday", ""},
+	}
+	for _, tt := range tests {
+		got, err := resolveDate(tt.expr, clock())
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("resolveDate(%q) = %v, want error", tt.expr, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveDate(%q): %v", tt.expr, err)
+			continue
+		}
+		if s := got.Format("2006-01-02"); s != tt.want || got.Location() != academicLoc || got.Hour() != 0 {
+			t.Errorf("resolveDate(%q) = %v, want midnight of %s in %s", tt.expr, got, tt.want, academicLoc)
+		}
+	}
+}
+
+func TestCalendarExportBoundaries(t *testing.T) {
+	setClock(t, lateUTC)
+	const uid, sid = "u1", "test-sid"
+	sessionsMu.Lock()
+	sessions[sid] = &Session{UID: uid, ExpiresAt: time.Now().Add(time.Hour)}
+	sessionsMu.Unlock()
+	t.Cleanup(func() {
+		sessionsMu.Lock()
+		delete(sessions, sid)
+		sessionsMu.Unlock()
+	})
+	// The default range is today (the 2nd) and the 13 days after it.
+	cacheDay(uid, "20260301", course("0", "Before", "2026-03-01 08:00:00", "2026-03-01 08:45:00"))
+	for d := 2; d <= 15; d++ {
+		cacheDay(uid, time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC).Format("20060102"))
+	}
+	cacheDay(uid, "20260302", course("1", "First", "2026-03-02 08:00:00", "2026-03-02 08:45:00"))
+	cacheDay(uid, "20260315", course("2", "Last", "2026-03-15 20:00:00", "2026-03-15 21:00:00"))
+	cacheDay(uid, "20260316", course("3", "After", "2026-03-16 08:00:00", "2026-03-16 08:45:00"))
+
+	tests := []struct {
+		query      string
+		want, dont []string
+	}{
+		{"", []string{"First", "Last"}, []string{"Before", "After"}},
+		{"?from=today&to=today", []string{"First"}, []string{"Before", "Last"}},
+		{"?from=20260315&to=20260315", []string{"Last"}, []string{"First", "After"}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/courses/calendar.ics"+tt.query, nil)
+		req.AddCookie(&http.Cookie{Name: cookieName, Value: sid})
+		rec := httptest.NewRecorder()
+		handleCalendarExport(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tt.query, rec.Code, rec.Body)
+		}
+		body := rec.Body.String()
+		for _, s := range tt.want {
+			if !strings.Contains(body, "SUMMARY:"+s) {
+				t.Errorf("%s: missing %s", tt.query, s)
+			}
+		}
+		for _, s := range tt.dont {
+			if strings.Contains(body, "SUMMARY:"+s) {
+				t.Errorf("%s: unexpected %s", tt.query, s)
+			}
+		}
+	}
+}
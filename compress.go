@@ -0,0 +1,163 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ------------------------------
+// Response compression
+// ------------------------------
+// compressHandler gzips responses for clients that accept it. A response
+// is left alone when it is small (under compressMinSize), already encoded
+// (the embedded /web/ assets pick their own precompressed variant), not a
+// text-like type (PNG, PDF), a Server-Sent Events stream, or carries a
+// strong ETag that would no longer match the bytes. Only gzip is
+// implemented; brotli is not offered anywhere, since the standard library
+// has no brotli encoder and the module takes no third-party dependencies.
+
+const compressMinSize = 1024
+
+var gzipPool = sync.Pool{New: func() any {
+	zw, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+	return zw
+}}
+
+func compressHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || !acceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, status: http.StatusOK}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter buffers the start of a response until it knows whether
+// compressing it is worthwhile.
+type compressWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool // the handler called WriteHeader
+	decided     bool
+	gz          *gzip.Writer
+	buf         []byte
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.wroteHeader || c.decided {
+		return
+	}
+	if status < http.StatusOK {
+		c.ResponseWriter.WriteHeader(status)
+		return
+	}
+	c.status, c.wroteHeader = status, true
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		c.passThrough()
+	}
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if !c.decided {
+		if !c.eligible() {
+			c.passThrough()
+		} else {
+			c.buf = append(c.buf, p...)
+			if len(c.buf) < compressMinSize {
+				return len(p), nil
+			}
+			if err := c.startGzip(); err != nil {
+				return 0, err
+			}
+			return len(p), nil
+		}
+	}
+	if c.gz != nil {
+		return c.gz.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// eligible inspects the headers the handler has set so far. It is called
+// on every buffered Write and must not change them.
+func (c *compressWriter) eligible() bool {
+	h := c.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	ctype := h.Get("Content-Type")
+	if ctype == "" || strings.HasPrefix(ctype, "text/event-stream") {
+		return false
+	}
+	return compressible(ctype) || strings.HasPrefix(ctype, "application/geo+json")
+}
+
+// passThrough sends the header and anything buffered unchanged.
+func (c *compressWriter) passThrough() {
+	c.decided = true
+	c.ResponseWriter.WriteHeader(c.status)
+	if len(c.buf) > 0 {
+		_, _ = c.ResponseWriter.Write(c.buf)
+		c.buf = nil
+	}
+}
+
+func (c *compressWriter) startGzip() error {
+	c.decided = true
+	h := c.Header()
+	h.Set("Content-Encoding", "gzip")
+	h.Add("Vary", "Accept-Encoding")
+	h.Del("Content-Length")
+	c.ResponseWriter.WriteHeader(c.status)
+	c.gz = gzipPool.Get().(*gzip.Writer)
+	c.gz.Reset(c.ResponseWriter)
+	_, err := c.gz.Write(c.buf)
+	c.buf = nil
+	return err
+}
+
+// Flush sends what is buffered so far; a streaming response that is
+// eligible is compressed from here on regardless of its size.
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		if c.eligible() && len(c.buf) > 0 {
+			_ = c.startGzip()
+		} else {
+			c.passThrough()
+		}
+	}
+	if c.gz != nil {
+		_ = c.gz.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection.
+func (c *compressWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+func (c *compressWriter) close() {
+	if !c.decided {
+		// Too small this time, but a longer body would be compressed.
+		if len(c.buf) > 0 && c.eligible() {
+			c.Header().Add("Vary", "Accept-Encoding")
+		}
+		c.passThrough()
+	}
+	if c.gz != nil {
+		_ = c.gz.Close()
+		c.gz.Reset(io.Discard)
+		gzipPool.Put(c.gz)
+		c.gz = nil
+	}
+}
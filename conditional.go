@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"strings"
+
+	"LoginTest/models"
+	"LoginTest/render"
+)
+
+// ------------------------------
+// Conditional requests
+// ------------------------------
+// The course endpoints are polled by clients that mostly see the same
+// timetable again. Their responses carry a weak ETag hashed from the
+// normalized payload — courses as models.Course, without the clock offset
+// "delta" that changes on every upstream call — so a GET with a matching
+// If-None-Match answers 304 without a body. The upstream is still asked
+// (the data has to be compared), only the transfer is saved.
+
+// payloadETag hashes parts, JSON-encoded, into a weak ETag.
+func payloadETag(parts ...any) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, p := range parts {
+		_ = enc.Encode(p)
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil))[:20] + `"`
+}
+
+// dayPayloadETag is the ETag of a one-day course payload built from
+// records and rendered in format f.
+func dayPayloadETag(payload map[string]any, records []models.CourseRecord, f render.Format) string {
+	p := maps.Clone(payload)
+	delete(p, "delta")
+	delete(p, "normalizeErrors")
+	p["result"], _ = models.NormalizeCourses(records)
+	return payloadETag(f, p)
+}
+
+// daysETag is the ETag of a multi-day response; extra covers the fields
+// outside the day views.
+func daysETag(days []dayView, f render.Format, extra ...any) string {
+	type normalizedDay struct {
+		dayView
+		Courses []models.Course `json:"courses"`
+	}
+	norm := make([]normalizedDay, len(days))
+	for i, d := range days {
+		norm[i].dayView = d
+		norm[i].Result = nil
+		norm[i].Courses, _ = models.NormalizeCourses(d.Result)
+	}
+	return payloadETag(append([]any{f, norm}, extra...)...)
+}
+
+// notModified sets etag on the response and reports whether the request
+// already has it, in which case 304 has been sent. Only GET and HEAD are
+// answered with 304; the format is negotiated by Accept, so caches are
+// told to key on it too. The payloads belong to the session user, so
+// shared caches must not keep them.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "private, no-cache")
+	h.Add("Vary", "Accept")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches applies the weak comparison of If-None-Match.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"LoginTest/events"
+)
+
+// ------------------------------
+// Schedule conflicts
+// ------------------------------
+// Upstream courses, personal events and imported calendars can overlap.
+// /schedule/conflicts lists every overlapping pair in a date range and,
+// with ?notify=1, publishes each new conflict as events.ScheduleConflict
+// (reaching hooks and SSE) and POSTs it to the user's webhooks. All-day
+// entries never conflict.
+
+// conflictView is one overlapping pair as served to clients.
+type conflictView struct {
+	ID             string       `json:"id"`
+	Date           string       `json:"date"`
+	First          scheduleItem `json:"first"`
+	Second         scheduleItem `json:"second"`
+	OverlapStart   time.Time    `json:"overlapStart"`
+	OverlapEnd     time.Time    `json:"overlapEnd"`
+	OverlapMinutes int          `json:"overlapMinutes"`
+	Reason         string       `json:"reason"`
+}
+
+var (
+	// conflicts already notified, keyed by uid|conflict id
+	notifiedConflicts   = map[string]time.Time{}
+	notifiedConflictsMu sync.Mutex
+)
+
+// findConflicts returns every overlapping pair of timed items, ordered by
+// the start of the overlap.
+func findConflicts(items []scheduleItem) []conflictView {
+	var timed []scheduleItem
+	seen := map[string]bool{}
+	for _, it := range items {
+		key := it.Source + "|" + it.ID + "|" + it.Begin.Format(time.RFC3339)
+		if it.AllDay || !it.End.After(it.Begin) || seen[key] {
+			continue
+		}
+		seen[key] = true
+		timed = append(timed, it)
+	}
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].Begin.Before(timed[j].Begin) })
+
+	var out []conflictView
+	for i := range timed {
+		for j := i + 1; j < len(timed) && timed[j].Begin.Before(timed[i].End); j++ {
+			a, b := timed[i], timed[j]
+			start, end := b.Begin, a.End
+			if b.End.Before(end) {
+				end = b.End
+			}
+			out = append(out, conflictView{
+				ID:             conflictID(a, b),
+				Date:           start.In(academicLoc).Format("20060102"),
+				First:          a,
+				Second:         b,
+				OverlapStart:   start,
+				OverlapEnd:     end,
+				OverlapMinutes: int(end.Sub(start).Round(time.Minute) / time.Minute),
+				Reason:         conflictReason(a, b, end.Sub(start)),
+			})
+		}
+	}
+	return out
+}
+
+// conflictID is stable across requests for the same pair.
+func conflictID(a, b scheduleItem) string {
+	ka := a.Source + ":" + a.ID + "@" + a.Begin.In(academicLoc).Format("20060102T1504")
+	kb := b.Source + ":" + b.ID + "@" + b.Begin.In(academicLoc).Format("20060102T1504")
+	if kb < ka {
+		ka, kb = kb, ka
+	}
+	return ka + "|" + kb
+}
+
+// conflictReason explains a conflict in one sentence.
+func conflictReason(a, b scheduleItem, overlap time.Duration) string {
+	describe := func(it scheduleItem) string {
+		kind := map[string]string{sourceCourse: "课程", sourcePersonal: "个人日程", sourceCalendar: "日历"}[it.Source]
+		if it.Source == sourceCalendar && it.Calendar != "" {
+			kind = "日历「" + it.Calendar + "」"
+		}
+		s := fmt.Sprintf("%s「%s」（%s-%s", kind, it.Title, it.Begin.In(academicLoc).Format("15:04"), it.End.In(academicLoc).Format("15:04"))
+		if it.Location != "" {
+			s += "，" + it.Location
+		}
+		return s + "）"
+	}
+	reason := fmt.Sprintf("%s 与 %s 重叠 %d 分钟", describe(a), describe(b), int(overlap.Round(time.Minute)/time.Minute))
+	if a.Begin.Equal(b.Begin) && a.End.Equal(b.End) {
+		reason += "，时间完全相同"
+	} else if !b.End.After(a.End) {
+		reason += "，后者完全包含在前者之内"
+	}
+	if a.Location != "" && b.Location != "" && a.Location != b.Location {
+		reason += "，且地点不同"
+	}
+	return reason
+}
+
+// handleScheduleConflicts lists overlapping entries of the session user.
+// GET /schedule/conflicts?from=<date expr>&to=<date expr> (default 7 days from today, at most 31)
+// ?notify=1 pushes conflicts that were not reported before to hooks and webhooks.
+func handleScheduleConflicts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+
+	q := r.URL.Query()
+	from, err := resolveDate(q.Get("from"), clock())
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to := from.AddDate(0, 0, 6)
+	if v := strings.TrimSpace(q.Get("to")); v != "" {
+		if to, err = resolveDate(v, clock()); err != nil {
+			http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "to is before from", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) >= maxRangeDays*24*time.Hour {
+		http.Error(w, fmt.Sprintf("range is limited to %d days", maxRangeDays), http.StatusBadRequest)
+		return
+	}
+
+	days, err := buildDays(sess, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	var items []scheduleItem
+	for _, d := range days {
+		items = append(items, d.Schedule...)
+	}
+	conflicts := findConflicts(items)
+	if conflicts == nil {
+		conflicts = []conflictView{}
+	}
+	notified := 0
+	if v := q.Get("notify"); v == "1" || v == "true" {
+		notified = notifyConflicts(sess.UID, conflicts)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"from":      from.Format("20060102"),
+		"to":        to.Format("20060102"),
+		"total":     len(conflicts),
+		"conflicts": conflicts,
+		"notified":  notified,
+	})
+}
+
+// notifyConflicts publishes and delivers conflicts not reported before and
+// returns how many were new.
+func notifyConflicts(uid string, conflicts []conflictView) int {
+	now := clock()
+	var fresh []conflictView
+	notifiedConflictsMu.Lock()
+	for k, end := range notifiedConflicts {
+		if now.Sub(end) > 24*time.Hour {
+			delete(notifiedConflicts, k)
+		}
+	}
+	for _, c := range conflicts {
+		key := uid + "|" + c.ID
+		if _, done := notifiedConflicts[key]; done {
+			continue
+		}
+		notifiedConflicts[key] = c.OverlapEnd
+		fresh = append(fresh, c)
+	}
+	notifiedConflictsMu.Unlock()
+
+	webhooksMu.RLock()
+	hooks := make([]Webhook, 0, len(webhooks[uid]))
+	for _, h := range webhooks[uid] {
+		hooks = append(hooks, *h)
+	}
+	webhooksMu.RUnlock()
+
+	for _, c := range fresh {
+		ev := events.ScheduleConflict{
+			UID:            uid,
+			ID:             c.ID,
+			Date:           c.Date,
+			First:          conflictEntry(c.First),
+			Second:         conflictEntry(c.Second),
+			OverlapMinutes: c.OverlapMinutes,
+			Reason:         c.Reason,
+		}
+		bus.Publish(ev)
+		for _, h := range hooks {
+			go deliverConflict(h, ev)
+		}
+	}
+	return len(fresh)
+}
+
+func conflictEntry(it scheduleItem) events.ConflictEntry {
+	return events.ConflictEntry{Source: it.Source, ID: it.ID, Title: it.Title, Begin: it.Begin, End: it.End}
+}
+
+// deliverConflict POSTs a conflict to a webhook. Reminder templates do not
+// apply; text webhooks get the reason, JSON webhooks the event with
+// "event": "schedule.conflict".
+func deliverConflict(h Webhook, ev events.ScheduleConflict) {
+	if h.Format == "text" {
+		postWebhook(h, []byte("日程冲突："+ev.Reason), "text/plain; charset=utf-8")
+		return
+	}
+	body, err := json.Marshal(struct {
+		Event string `json:"event"`
+		events.ScheduleConflict
+	}{events.TypeScheduleConflict, ev})
+	if err != nil {
+		return
+	}
+	postWebhook(h, body, "application/json")
+}
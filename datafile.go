@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stateDir is where the server keeps its JSON state files (webhooks,
+// digests, personal events, calendar feeds, ...). It must not be served:
+// unlike the course dumps in data/, these files belong to individual
+// users. STATE_DIR moves it; files still found under the old location
+// data/ are read from there until they are next written.
+var stateDir = "state"
+
+// dumpDir holds the course dumps served under /data/; state files were
+// kept there too before STATE_DIR.
+const dumpDir = "data"
+
+// loadStateConfig reads STATE_DIR.
+func loadStateConfig() {
+	if v := os.Getenv("STATE_DIR"); v != "" {
+		stateDir = v
+	}
+}
+
+// readStateFile reads <stateDir>/<name>, falling back to data/<name>.
+func readStateFile(name string) ([]byte, error) {
+	b, err := os.ReadFile(filepath.Join(stateDir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		b, err = os.ReadFile(filepath.Join(dumpDir, name))
+	}
+	return b, err
+}
+
+// loadDataFile decodes the state file name into v. A missing file is not
+// an error.
+func loadDataFile(name string, v any) error {
+	b, err := readStateFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// saveDataFile writes v as indented JSON to the state file name, replacing
+// the old content atomically so a crash never leaves a half-written file
+// behind.
+func saveDataFile(name string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeDataFile(name, b)
+}
+
+// writeDataFile atomically replaces the state file name (which may contain
+// a subdirectory) with b.
+func writeDataFile(name string, b []byte) error {
+	path := filepath.Join(stateDir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// A temp file of its own per call, so concurrent writers of the same
+	// name never rename each other's half-written file.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// removeDataFile deletes the state file name from both locations.
+func removeDataFile(name string) {
+	_ = os.Remove(filepath.Join(stateDir, name))
+	_ = os.Remove(filepath.Join(dumpDir, name))
+}
+
+// handleCourseDump serves the demo course dumps data/courses_<dateStr>.json
+// and nothing else from data/: no listings, no other files.
+func handleCourseDump(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/data/")
+	if strings.ContainsAny(name, `/\`) || !strings.HasPrefix(name, "courses_") || !strings.HasSuffix(name, ".json") {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, filepath.Join(dumpDir, name))
+}
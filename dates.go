@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------------------------
+// Date expressions
+// ------------------------------
+// Course endpoints accept more than YYYYMMDD in dateStr:
+//   20261017, 2026-10-17, 2026/10/17
+//   today, tomorrow, yesterday (今天, 明天, 昨天)
+//   mon..sun or this-mon      that weekday of the current week (Mon-Sun)
+//   next-mon / last-mon       the first Monday after / before today
+//   week:5:tue                Tuesday of teaching week 5
+// Everything is resolved in the academic time zone.
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// resolveDate turns expr into midnight of a calendar day in academicLoc.
+// An empty expr means today.
+func resolveDate(expr string, now time.Time) (time.Time, error) {
+	now = now.In(academicLoc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, academicLoc)
+	e := strings.ToLower(strings.TrimSpace(expr))
+
+	switch e {
+	case "", "today", "今天":
+		return today, nil
+	case "tomorrow", "明天":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday", "昨天":
+		return today.AddDate(0, 0, -1), nil
+	}
+	for _, layout := range []string{"20060102", "2006-01-02", "2006/01/02"} {
+		if t, err := time.ParseInLocation(layout, e, academicLoc); err == nil {
+			return t, nil
+		}
+	}
+
+	if rest, ok := strings.CutPrefix(e, "week:"); ok {
+		weekStr, dayStr, _ := strings.Cut(rest, ":")
+		n, err := strconv.Atoi(weekStr)
+		if err != nil || n < 1 {
+			return time.Time{}, fmt.Errorf("invalid teaching week %q", weekStr)
+		}
+		wd := time.Monday
+		if dayStr != "" {
+			if wd, ok = weekdayNames[dayStr]; !ok {
+				return time.Time{}, fmt.Errorf("invalid weekday %q", dayStr)
+			}
+		}
+		start, err := teachingWeekStart(n, today)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return start.AddDate(0, 0, daysFromMonday(wd)), nil
+	}
+
+	prefix, name, found := strings.Cut(e, "-")
+	if !found {
+		prefix, name = "this", e
+	}
+	wd, ok := weekdayNames[name]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unrecognised date %q", expr)
+	}
+	switch prefix {
+	case "this":
+		monday := today.AddDate(0, 0, -daysFromMonday(today.Weekday()))
+		return monday.AddDate(0, 0, daysFromMonday(wd)), nil
+	case "next":
+		diff := (int(wd) - int(today.Weekday()) + 7) % 7
+		if diff == 0 {
+			diff = 7
+		}
+		return today.AddDate(0, 0, diff), nil
+	case "last":
+		diff := (int(today.Weekday()) - int(wd) + 7) % 7
+		if diff == 0 {
+			diff = 7
+		}
+		return today.AddDate(0, 0, -diff), nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", expr)
+}
+
+// resolveDateStr is resolveDate formatted as the upstream dateStr.
+func resolveDateStr(expr string) (string, error) {
+	t, err := resolveDate(expr, clock())
+	if err != nil {
+		return "", err
+	}
+	return t.Format("20060102"), nil
+}
+
+// daysFromMonday maps Monday..Sunday to 0..6.
+func daysFromMonday(wd time.Weekday) int {
+	return (int(wd) + 6) % 7
+}
+
+// teachingWeekStart returns the Monday of teaching week n of the semester
+// that today belongs to (see semesters.go).
+func teachingWeekStart(n int, today time.Time) (time.Time, error) {
+	s, ok := semesterFor(today)
+	if !ok {
+		return time.Time{}, fmt.Errorf("teaching weeks unknown: no semester configured or learned yet")
+	}
+	return s.WeekStart(n)
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"LoginTest/models"
+)
+
+// ------------------------------
+// Daily schedule digest (SMTP)
+// ------------------------------
+// Users opt in with an email address. Once a day at DIGEST_TIME the server
+// renders tomorrow's courses into a text+HTML email and sends it through
+// the SMTP relay configured by SMTP_ADDR. Rooms that differ from the
+// previous fetch of the same day are highlighted.
+
+const (
+	digestsFile       = "digests.json"
+	defaultDigestTime = "21:00"
+)
+
+// DigestSubscription is the opt-in state of one user.
+type DigestSubscription struct {
+	Email    string `json:"email"`
+	Enabled  bool   `json:"enabled"`
+	UserName string `json:"userName,omitempty"`
+	// LastSent is the dateStr (YYYYMMDD) of the day the last digest went out.
+	LastSent string `json:"lastSent,omitempty"`
+}
+
+// smtpConfig is read from the environment once at startup.
+type smtpConfig struct {
+	Addr     string // host:port of the relay, digest is disabled when empty
+	From     string
+	Username string
+	Password string
+	SendAt   string // HH:MM in the academic time zone
+}
+
+// digestCourse is one row of the digest.
+type digestCourse struct {
+	models.CourseRecord
+	RoomChanged  bool
+	PreviousRoom string
+}
+
+// digestData feeds both digest templates.
+type digestData struct {
+	UserName string
+	Date     string
+	DayLabel string // holiday / 调休 label from the academic calendar
+	// Unavailable is set when neither upstream nor the cache had the day,
+	// so the mail must not claim that there are no classes.
+	Unavailable bool
+	Courses     []digestCourse
+}
+
+var (
+	// uid -> subscription
+	digests   = map[string]*DigestSubscription{}
+	digestsMu sync.Mutex
+
+	smtpCfg smtpConfig
+)
+
+var digestTextTmpl = template.Must(template.New("digest.txt").Parse(
+	`{{.UserName}}，你好：
+
+{{.Date}} 的课程安排如下：
+{{if .DayLabel}}（{{.DayLabel}}）
+{{end}}{{if .Unavailable}}
+暂时无法获取明天的课表，请登录后自行查看。
+{{else}}{{range .Courses}}
+- {{.ClassBeginTime}} ~ {{.ClassEndTime}}  {{.CourseName}}
+  教师：{{.TeacherName}}  地点：{{.TeachBuildName}} {{.ClassroomName}}{{if .RoomChanged}}  [教室变更，原：{{.PreviousRoom}}]{{end}}
+{{else}}
+明天没有课程。
+{{end}}{{end}}`))
+
+var digestHTMLTmpl = htmltemplate.Must(htmltemplate.New("digest.html").Parse(
+	`<!DOCTYPE html>
+<html lang="zh-CN"><body style="font-family:sans-serif">
+<p>{{.UserName}}，你好：</p>
+<p>{{.Date}} 的课程安排如下：</p>
+{{if .DayLabel}}<p style="color:#b45309"><strong>{{.DayLabel}}</strong></p>{{end}}
+{{if .Unavailable}}<p>暂时无法获取明天的课表，请登录后自行查看。</p>
+{{else if .Courses}}<table cellpadding="6" style="border-collapse:collapse">
+<tr><th align="left">时间</th><th align="left">课程</th><th align="left">教师</th><th align="left">地点</th></tr>
+{{range .Courses}}<tr>
+<td>{{.ClassBeginTime}} ~ {{.ClassEndTime}}</td>
+<td>{{.CourseName}}</td>
+<td>{{.TeacherName}}</td>
+<td{{if .RoomChanged}} style="background:#fef3c7;font-weight:bold"{{end}}>{{.TeachBuildName}} {{.ClassroomName}}{{if .RoomChanged}}<br><small>教室变更，原：{{.PreviousRoom}}</small>{{end}}</td>
+</tr>
+{{end}}</table>{{else}}<p>明天没有课程。</p>{{end}}
+</body></html>
+`))
+
+// loadDigestConfig reads SMTP settings and the stored subscriptions.
+func loadDigestConfig() {
+	smtpCfg = smtpConfig{
+		Addr:     os.Getenv("SMTP_ADDR"),
+		From:     os.Getenv("SMTP_FROM"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		SendAt:   os.Getenv("DIGEST_TIME"),
+	}
+	if smtpCfg.SendAt == "" {
+		smtpCfg.SendAt = defaultDigestTime
+	}
+	if _, err := time.Parse("15:04", smtpCfg.SendAt); err != nil {
+		log.Printf("ignoring invalid DIGEST_TIME %q", smtpCfg.SendAt)
+		smtpCfg.SendAt = defaultDigestTime
+	}
+	if smtpCfg.From == "" {
+		smtpCfg.From = "ucas-course@localhost"
+	}
+
+	digestsMu.Lock()
+	defer digestsMu.Unlock()
+	if err := loadDataFile(digestsFile, &digests); err != nil {
+		log.Printf("load digests failed: %v", err)
+	}
+	if digests == nil {
+		digests = map[string]*DigestSubscription{}
+	}
+}
+
+// saveDigestsLocked persists subscriptions; caller must hold digestsMu.
+func saveDigestsLocked() {
+	if err := saveDataFile(digestsFile, digests); err != nil {
+		log.Printf("save digests failed: %v", err)
+	}
+}
+
+// handleDigest manages the digest subscription of the session user.
+// GET    -> { digest: {...}, sendAt: "HH:MM", smtpConfigured: bool }
+// PUT    JSON { email, enabled } -> { digest }
+// DELETE -> 204
+func handleDigest(w http.ResponseWriter, r *http.Request) {
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+
+	switch r.Method {
+	case http.MethodGet:
+		digestsMu.Lock()
+		var sub *DigestSubscription
+		if d, ok := digests[sess.UID]; ok {
+			copied := *d
+			sub = &copied
+		}
+		digestsMu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"digest":         sub,
+			"sendAt":         smtpCfg.SendAt,
+			"smtpConfigured": smtpCfg.Addr != "",
+		})
+
+	case http.MethodPut, http.MethodPost:
+		var body DigestSubscription
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+			return
+		}
+		addr, err := mail.ParseAddress(strings.TrimSpace(body.Email))
+		if err != nil {
+			http.Error(w, "invalid email", http.StatusBadRequest)
+			return
+		}
+		digestsMu.Lock()
+		sub, ok := digests[sess.UID]
+		if !ok {
+			sub = &DigestSubscription{}
+			digests[sess.UID] = sub
+		}
+		sub.Email = addr.Address
+		sub.Enabled = body.Enabled
+		sub.UserName = displayName(sess.User.RealName, sess.User.UserName)
+		copied := *sub
+		saveDigestsLocked()
+		digestsMu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"digest": copied,
+		})
+
+	case http.MethodDelete:
+		digestsMu.Lock()
+		delete(digests, sess.UID)
+		saveDigestsLocked()
+		digestsMu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDigestSend sends tomorrow's digest right away, mainly for testing
+// the SMTP setup against a local catcher.
+func handleDigestSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+	if smtpCfg.Addr == "" {
+		http.Error(w, "smtp is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	digestsMu.Lock()
+	sub, ok := digests[sess.UID]
+	var copied DigestSubscription
+	if ok {
+		copied = *sub
+	}
+	digestsMu.Unlock()
+	if !ok {
+		http.Error(w, "digest not configured", http.StatusNotFound)
+		return
+	}
+	if err := sendDigest(sess.UID, copied, clock()); err != nil {
+		log.Printf("send digest failed: %v", err)
+		http.Error(w, "send digest failed", http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startDigestScheduler checks once a minute whether the daily digest is due.
+func startDigestScheduler() {
+	if smtpCfg.Addr == "" {
+		log.Printf("SMTP_ADDR not set, daily digest disabled")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			runDigests(clock())
+		}
+	}()
+}
+
+// runDigests sends the digest to every subscriber that has not received
+// today's one yet, once the configured time of day has passed.
+func runDigests(now time.Time) {
+	now = now.In(academicLoc)
+	if now.Format("15:04") < smtpCfg.SendAt {
+		return
+	}
+	today := now.Format("20060102")
+
+	digestsMu.Lock()
+	due := map[string]DigestSubscription{}
+	for uid, sub := range digests {
+		if sub.Enabled && sub.LastSent != today {
+			due[uid] = *sub
+		}
+	}
+	digestsMu.Unlock()
+
+	for uid, sub := range due {
+		if err := sendDigest(uid, sub, now); err != nil {
+			log.Printf("send digest for %s failed: %v", maskID(uid), err)
+			continue
+		}
+		digestsMu.Lock()
+		if d, ok := digests[uid]; ok {
+			d.LastSent = today
+		}
+		saveDigestsLocked()
+		digestsMu.Unlock()
+	}
+}
+
+// sendDigest renders and mails tomorrow's schedule (relative to now).
+func sendDigest(uid string, sub DigestSubscription, now time.Time) error {
+	date := now.In(academicLoc).AddDate(0, 0, 1)
+	dateStr := date.Format("20060102")
+
+	// Refresh from upstream while the user still has a live session, so the
+	// room comparison sees the latest data; otherwise use the cache.
+	if sess := sessionForUID(uid); sess != nil {
+		if _, _, _, _, err := fetchCourses(sess, dateStr); err != nil {
+			log.Printf("refresh courses for digest failed: %v", err)
+		}
+	}
+	snap, ok := cachedCourses(uid, dateStr)
+
+	data := digestData{
+		UserName:    sub.UserName,
+		Date:        date.Format("2006-01-02"),
+		Unavailable: !ok,
+	}
+	if ok {
+		data.Courses = digestCourses(snap)
+	}
+	if data.UserName == "" {
+		data.UserName = "同学"
+	}
+	if d, ok := calendarDay(dateStr); ok {
+		data.DayLabel = d.Label()
+	}
+	msg, err := buildDigestMessage(sub.Email, data)
+	if err != nil {
+		return err
+	}
+	var a smtp.Auth
+	if smtpCfg.Username != "" {
+		host := smtpCfg.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		a = smtp.PlainAuth("", smtpCfg.Username, smtpCfg.Password, host)
+	}
+	return smtp.SendMail(smtpCfg.Addr, a, smtpCfg.From, []string{sub.Email}, msg)
+}
+
+// digestCourses sorts the snapshot by begin time and marks room changes
+// against the previous fetch of the same day.
+func digestCourses(snap courseSnapshot) []digestCourse {
+	prevRoom := map[string]string{}
+	if snap.Previous != nil {
+		for _, c := range snap.Previous.Result {
+			prevRoom[c.ID] = strings.TrimSpace(c.TeachBuildName + " " + c.ClassroomName)
+		}
+	}
+	out := make([]digestCourse, 0, len(snap.Courses.Result))
+	for _, c := range snap.Courses.Result {
+		dc := digestCourse{CourseRecord: c}
+		room := strings.TrimSpace(c.TeachBuildName + " " + c.ClassroomName)
+		if old, ok := prevRoom[c.ID]; ok && old != room {
+			dc.RoomChanged = true
+			dc.PreviousRoom = old
+		}
+		out = append(out, dc)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].ClassBeginTime < out[j].ClassBeginTime
+	})
+	return out
+}
+
+// buildDigestMessage assembles a multipart/alternative email.
+func buildDigestMessage(to string, data digestData) ([]byte, error) {
+	var text, html bytes.Buffer
+	if err := digestTextTmpl.Execute(&text, data); err != nil {
+		return nil, err
+	}
+	if err := digestHTMLTmpl.Execute(&html, data); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=UTF-8", text.Bytes()},
+		{"text/html; charset=UTF-8", html.Bytes()},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pw.Write(part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	subject := fmt.Sprintf("明日课程 %s（%d 节）", data.Date, len(data.Courses))
+	if data.Unavailable {
+		subject = fmt.Sprintf("明日课程 %s（课表暂不可用）", data.Date)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", smtpCfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// sessionForUID returns any live session of uid, or nil.
+func sessionForUID(uid string) *Session {
+	sessionsMu.RLock()
+	defer sessionsMu.RUnlock()
+	now := time.Now()
+	for _, sess := range sessions {
+		if sess.UID == uid && now.Before(sess.ExpiresAt) {
+			return sess
+		}
+	}
+	return nil
+}
+
+// displayName picks the first non-empty name.
+func displayName(names ...string) string {
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			return n
+		}
+	}
+	return ""
+}
+
+// maskID keeps log lines free of full user ids.
+func maskID(id string) string {
+	if len(id) <= 4 {
+		return "****"
+	}
+	return id[:2] + "****" + id[len(id)-2:]
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"LoginTest/models"
+)
+
+// smtpStub accepts one connection on loopback, speaks just enough SMTP for
+// smtp.SendMail (no STARTTLS, no AUTH) and hands over the DATA section.
+func smtpStub(t *testing.T) (addr string, msgs <-chan []byte) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	out := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 stub ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 stub")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				b, err := io.ReadAll(tp.DotReader())
+				if err != nil {
+					return
+				}
+				out <- b
+				tp.PrintfLine("250 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), out
+}
+
+func TestSendDigest(t *testing.T) {
+	setClock(t, lateUTC)
+	addr, msgs := smtpStub(t)
+	oldCfg := smtpCfg
+	smtpCfg = smtpConfig{Addr: addr, From: "digest@example.com", SendAt: defaultDigestTime}
+	t.Cleanup(func() { smtpCfg = oldCfg })
+
+	const uid = "u1"
+	moved := course("1", "线性代数", "2026-03-03 08:00:00", "2026-03-03 09:35:00")
+	moved.TeacherName, moved.TeachBuildName, moved.ClassroomName = "王老师", "教一楼", "102"
+	stayed := course("2", "概率论", "2026-03-03 10:00:00", "2026-03-03 11:35:00")
+	stayed.TeachBuildName, stayed.ClassroomName = "教二楼", "201"
+	// Tomorrow in Beijing is the 3rd; the earlier fetch had linear algebra
+	// in another room.
+	cacheDay(uid, "20260303", stayed, moved)
+	before := moved
+	before.ClassroomName = "305"
+	courseCache[uid]["20260303"].Previous = &models.TodayCoursesResponse{
+		STATUS: "0",
+		Result: []models.CourseRecord{before, stayed},
+	}
+
+	sub := DigestSubscription{Email: "student@example.com", Enabled: true, UserName: "张三"}
+	if err := sendDigest(uid, sub, clock()); err != nil {
+		t.Fatalf("sendDigest: %v", err)
+	}
+	var raw []byte
+	select {
+	case raw = <-msgs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message reached the SMTP stub")
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if want := "明日课程 2026-03-03（2 节）"; subject != want {
+		t.Errorf("Subject = %q, want %q", subject, want)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		b, _ := io.ReadAll(p)
+		parts[ct] = string(b)
+	}
+
+	text, html := parts["text/plain"], parts["text/html"]
+	if text == "" || html == "" {
+		t.Fatalf("parts = %v, want text/plain and text/html", len(parts))
+	}
+	tests := []struct {
+		part, body string
+		want       []string
+	}{
+		{"text", text, []string{
+			"张三，你好",
+			"2026-03-03 08:00:00 ~ 2026-03-03 09:35:00  线性代数",
+			"地点：教一楼 102  [教室变更，原：教一楼 305]",
+			"地点：教二楼 201\n",
+		}},
+		{"html", html, []string{
+			`<td style="background:#fef3c7;font-weight:bold">教一楼 102<br><small>教室变更，原：教一楼 305</small></td>`,
+			"<td>教二楼 201</td>",
+		}},
+	}
+	for _, tt := range tests {
+		for _, w := range tt.want {
+			if !strings.Contains(tt.body, w) {
+				t.Errorf("%s part lacks %q:\n%s", tt.part, w, tt.body)
+			}
+		}
+	}
+	if strings.Index(text, "线性代数") > strings.Index(text, "概率论") {
+		t.Errorf("text part is not ordered by begin time:\n%s", text)
+	}
+	if n := strings.Count(html, "教室变更"); n != 1 {
+		t.Errorf("html highlights %d rooms, want 1", n)
+	}
+}
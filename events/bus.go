@@ -0,0 +1,133 @@
+// Package events is a small in-process publish/subscribe bus. HTTP handlers
+// publish typed events (see types.go) and two kinds of consumers receive
+// them: per-user subscriptions such as the SSE stream, and Hooks registered
+// at startup that see every event.
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Event is one published message. IDs are assigned by the bus and grow
+// monotonically, so clients can resume after the last one they saw.
+type Event struct {
+	ID   uint64    `json:"id"`
+	UID  string    `json:"-"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data Payload   `json:"data,omitempty"`
+}
+
+// Subscription receives the events of one user until Close is called. When
+// the subscriber falls behind its channel is closed; it should reconnect and
+// replay with Since.
+type Subscription struct {
+	uid    string
+	ch     chan Event
+	bus    *Bus
+	closed bool
+}
+
+// C returns the delivery channel.
+func (s *Subscription) C() <-chan Event { return s.ch }
+
+// Close detaches the subscription from the bus.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.detachLocked(s)
+}
+
+// Bus fans events out to the subscribers of their user and keeps a bounded
+// history for resuming.
+type Bus struct {
+	mu      sync.Mutex
+	nextID  uint64
+	subs    map[string]map[*Subscription]struct{}
+	history []Event
+	size    int
+	hooks   []hookRunner
+}
+
+// NewBus creates a bus that remembers the last historySize events.
+func NewBus(historySize int) *Bus {
+	if historySize <= 0 {
+		historySize = 1
+	}
+	return &Bus{
+		subs: map[string]map[*Subscription]struct{}{},
+		size: historySize,
+	}
+}
+
+// Publish stamps p with an ID, delivers it to the subscribers of its user
+// and queues it for every hook.
+func (b *Bus) Publish(p Payload) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	ev := Event{ID: b.nextID, UID: p.UserID(), Type: p.EventType(), Time: time.Now(), Data: p}
+	b.history = append(b.history, ev)
+	if len(b.history) > b.size {
+		b.history = b.history[len(b.history)-b.size:]
+	}
+	if ev.UID != "" {
+		for s := range b.subs[ev.UID] {
+			select {
+			case s.ch <- ev:
+			default:
+				// Slow consumer: drop it instead of blocking publishers.
+				b.detachLocked(s)
+			}
+		}
+	}
+	for _, h := range b.hooks {
+		select {
+		case h.queue <- ev:
+		default:
+			log.Printf("hook %s is falling behind, dropping %s #%d", h.hook.Name(), ev.Type, ev.ID)
+		}
+	}
+	return ev
+}
+
+// Subscribe registers a new subscriber for uid.
+func (b *Bus) Subscribe(uid string, buffer int) *Subscription {
+	s := &Subscription{uid: uid, ch: make(chan Event, buffer), bus: b}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[uid] == nil {
+		b.subs[uid] = map[*Subscription]struct{}{}
+	}
+	b.subs[uid][s] = struct{}{}
+	return s
+}
+
+// Since returns uid's events published after lastID that are still in the
+// history. complete is false when older events were already evicted, i.e.
+// the caller missed something.
+func (b *Bus) Since(uid string, lastID uint64) (list []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	complete = len(b.history) == 0 || b.history[0].ID <= lastID+1
+	for _, ev := range b.history {
+		if ev.ID > lastID && ev.UID == uid {
+			list = append(list, ev)
+		}
+	}
+	return list, complete
+}
+
+func (b *Bus) detachLocked(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(b.subs[s.uid], s)
+	if len(b.subs[s.uid]) == 0 {
+		delete(b.subs, s.uid)
+	}
+	close(s.ch)
+}
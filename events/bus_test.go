@@ -0,0 +1,58 @@
+package events
+
+import "testing"
+
+func TestBusSince(t *testing.T) {
+	// The history keeps 4 events: after 7 publishes only 4..7 are left.
+	b := NewBus(4)
+	for _, uid := range []string{"a", "b", "a", "a", "b", "a"} {
+		b.Publish(LoginSucceeded{UID: uid})
+	}
+	b.Publish(LoginFailed{Phone: "138****0000"}) // 7, without a user
+
+	tests := []struct {
+		name     string
+		uid      string
+		lastID   uint64
+		want     []uint64
+		complete bool
+	}{
+		{"everything missed", "a", 0, []uint64{4, 6}, false},
+		{"resume inside the evicted part", "a", 2, []uint64{4, 6}, false},
+		{"resume just before the oldest kept", "a", 3, []uint64{4, 6}, true},
+		{"resume in the middle", "a", 4, []uint64{6}, true},
+		{"other user", "b", 3, []uint64{5}, true},
+		{"up to date", "a", 7, nil, true},
+		{"id from the future", "a", 99, nil, true},
+	}
+	for _, tt := range tests {
+		list, complete := b.Since(tt.uid, tt.lastID)
+		var got []uint64
+		for _, ev := range list {
+			got = append(got, ev.ID)
+			if ev.UID != tt.uid {
+				t.Errorf("%s: replayed event #%d of user %q", tt.name, ev.ID, ev.UID)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: Since(%q, %d) = %v, want %v", tt.name, tt.uid, tt.lastID, got, tt.want)
+		} else {
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("%s: Since(%q, %d) = %v, want %v", tt.name, tt.uid, tt.lastID, got, tt.want)
+					break
+				}
+			}
+		}
+		if complete != tt.complete {
+			t.Errorf("%s: complete = %v, want %v", tt.name, complete, tt.complete)
+		}
+	}
+}
+
+func TestBusSinceEmpty(t *testing.T) {
+	list, complete := NewBus(4).Since("a", 0)
+	if len(list) != 0 || !complete {
+		t.Errorf("Since on an empty bus = %v, %v; want nothing, complete", list, complete)
+	}
+}
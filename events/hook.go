@@ -0,0 +1,54 @@
+package events
+
+import (
+	"log"
+)
+
+// hookQueue bounds how many events may wait for one slow hook.
+const hookQueue = 256
+
+// Hook receives every event published on a bus. Hooks run on their own
+// goroutine, one event at a time and in publish order, so a slow hook
+// never blocks the HTTP handler that published the event.
+type Hook interface {
+	Name() string
+	Handle(Event)
+}
+
+// HookFunc adapts a plain function to Hook.
+type HookFunc struct {
+	HookName string
+	Fn       func(Event)
+}
+
+func (h HookFunc) Name() string    { return h.HookName }
+func (h HookFunc) Handle(ev Event) { h.Fn(ev) }
+
+// Register attaches h to the bus. It is meant to be called at startup,
+// typically from an init function of the integration.
+func (b *Bus) Register(h Hook) {
+	q := make(chan Event, hookQueue)
+	b.mu.Lock()
+	b.hooks = append(b.hooks, hookRunner{hook: h, queue: q})
+	b.mu.Unlock()
+	go func() {
+		for ev := range q {
+			runHook(h, ev)
+		}
+	}()
+}
+
+type hookRunner struct {
+	hook  Hook
+	queue chan Event
+}
+
+// runHook isolates the bus from panicking hooks.
+func runHook(h Hook, ev Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("hook %s panicked on %s: %v", h.Name(), ev.Type, r)
+		}
+	}()
+	h.Handle(ev)
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"time"
+
+	"LoginTest/models"
+)
+
+// Payload is implemented by every typed event. EventType is the wire name
+// (also used as SSE event name) and UserID routes the event to subscribers.
+type Payload interface {
+	EventType() string
+	UserID() string
+}
+
+// Event type names.
+const (
+	TypeLoginSucceeded   = "login.succeeded"
+	TypeLoginFailed      = "login.failed"
+	TypeCoursesFetched   = "courses.fetched"
+	TypeCourseChanged    = "courses.changed"
+	TypeSignAttempted    = "sign.attempted"
+	TypeSessionExpired   = "session.expired"
+	TypeScheduleConflict = "schedule.conflict"
+)
+
+// LoginSucceeded is published after a local session was created.
+type LoginSucceeded struct {
+	UID      string `json:"-"`
+	UserName string `json:"userName"`
+}
+
+// LoginFailed is published when upstream rejected or garbled a login. The
+// phone number is masked; there is no user id yet.
+type LoginFailed struct {
+	Phone  string `json:"phone"`
+	Reason string `json:"reason"`
+	Status int    `json:"status"`
+}
+
+// CoursesFetched carries a freshly fetched timetable of one day.
+type CoursesFetched struct {
+	UID     string                `json:"-"`
+	Date    string                `json:"date"` // YYYYMMDD
+	Total   int                   `json:"total"`
+	Courses []models.CourseRecord `json:"result"`
+}
+
+// CourseChanged is one difference between two fetches of the same day.
+type CourseChanged struct {
+	UID        string `json:"-"`
+	ID         string `json:"id"`
+	ChangeType string `json:"type"` // course.added, course.removed or course.changed
+	Date       string `json:"date"`
+	CourseID   string `json:"courseId"`
+	CourseName string `json:"courseName"`
+	Field      string `json:"field,omitempty"`
+	Old        string `json:"old,omitempty"`
+	New        string `json:"new,omitempty"`
+}
+
+// SignAttempted reports the upstream answer to a sign-in request.
+type SignAttempted struct {
+	UID         string `json:"-"`
+	TimeTableID string `json:"timeTableId"`
+	Status      int    `json:"status"`
+	Response    string `json:"response,omitempty"`
+}
+
+// SessionExpired is published when a local session timed out.
+type SessionExpired struct {
+	UID       string    `json:"-"`
+	ExpiredAt time.Time `json:"expiredAt"`
+}
+
+// ScheduleConflict reports two overlapping entries of a user's schedule.
+type ScheduleConflict struct {
+	UID            string        `json:"-"`
+	ID             string        `json:"id"`
+	Date           string        `json:"date"` // YYYYMMDD of the overlap start
+	First          ConflictEntry `json:"first"`
+	Second         ConflictEntry `json:"second"`
+	OverlapMinutes int           `json:"overlapMinutes"`
+	Reason         string        `json:"reason"`
+}
+
+// ConflictEntry is one side of a ScheduleConflict.
+type ConflictEntry struct {
+	Source string    `json:"source"` // course, personal or calendar
+	ID     string    `json:"id"`
+	Title  string    `json:"title"`
+	Begin  time.Time `json:"begin"`
+	End    time.Time `json:"end"`
+}
+
+func (LoginSucceeded) EventType() string   { return TypeLoginSucceeded }
+func (LoginFailed) EventType() string      { return TypeLoginFailed }
+func (CoursesFetched) EventType() string   { return TypeCoursesFetched }
+func (CourseChanged) EventType() string    { return TypeCourseChanged }
+func (SignAttempted) EventType() string    { return TypeSignAttempted }
+func (SessionExpired) EventType() string   { return TypeSessionExpired }
+func (ScheduleConflict) EventType() string { return TypeScheduleConflict }
+
+func (e LoginSucceeded) UserID() string   { return e.UID }
+func (LoginFailed) UserID() string        { return "" }
+func (e CoursesFetched) UserID() string   { return e.UID }
+func (e CourseChanged) UserID() string    { return e.UID }
+func (e SignAttempted) UserID() string    { return e.UID }
+func (e SessionExpired) UserID() string   { return e.UID }
+func (e ScheduleConflict) UserID() string { return e.UID }
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"LoginTest/ical"
+)
+
+// ------------------------------
+// External ICS calendars
+// ------------------------------
+// Users can upload an .ics file or register an ICS URL to mirror (a lab's
+// group-meeting calendar, a club's events). Feed metadata lives in
+// state/calendar_feeds.json, the raw ICS of each feed in state/feeds/<id>.ics
+// so it survives restarts. Mirrored feeds are re-fetched every
+// FEED_REFRESH_INTERVAL (default 1h) with conditional requests; on failure
+// the last good copy stays in use.
+// Feeds are fetched with a client that only reaches public addresses (see
+// outbound.go); FEED_ALLOWED_NETS opens e.g. a campus calendar server.
+
+const (
+	calendarFeedsFile          = "calendar_feeds.json"
+	feedsDir                   = "feeds"
+	maxFeedsPerUser            = 20
+	defaultFeedRefreshInterval = time.Hour
+)
+
+// CalendarFeed is one imported calendar owned by a user.
+type CalendarFeed struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// URL is empty for uploaded files, which never refresh.
+	URL          string    `json:"url,omitempty"`
+	ETag         string    `json:"-"`
+	LastModified string    `json:"-"`
+	LastFetched  time.Time `json:"lastFetched,omitempty"`
+	LastError    string    `json:"lastError,omitempty"`
+	EventCount   int       `json:"eventCount"`
+	CreatedAt    time.Time `json:"createdAt"`
+	// deleted is set under feedsMu when the feed is removed, so that a
+	// refresh still in flight does not bring its events or file back.
+	deleted bool
+}
+
+// feedRecord is what state/calendar_feeds.json stores; it keeps the
+// validators that are hidden from API responses.
+type feedRecord struct {
+	CalendarFeed
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+var (
+	// uid -> feeds
+	calendarFeeds = map[string][]*CalendarFeed{}
+	// feed id -> parsed events
+	feedEvents = map[string][]ical.Event{}
+	feedsMu    sync.RWMutex
+
+	feedClient = publicOnlyClient(20*time.Second, &feedAllowedNets)
+
+	// networks an operator allowed despite being non-public
+	feedAllowedNets []*net.IPNet
+)
+
+var (
+	errFeedDeleted     = errors.New("calendar was deleted")
+	errFeedAddress     = errors.New("calendar url points to a non-public address")
+	errFeedUnreachable = errors.New("calendar server unreachable")
+	errFeedBadStatus   = errors.New("calendar server did not return a calendar")
+)
+
+// loadFeedFetchConfig reads FEED_ALLOWED_NETS.
+func loadFeedFetchConfig() {
+	feedAllowedNets = loadAllowedNets("FEED_ALLOWED_NETS")
+}
+
+// loadCalendarFeeds restores feed metadata and parses the stored ICS files.
+func loadCalendarFeeds() {
+	stored := map[string][]feedRecord{}
+	if err := loadDataFile(calendarFeedsFile, &stored); err != nil {
+		log.Printf("load calendar feeds failed: %v", err)
+	}
+	feedsMu.Lock()
+	defer feedsMu.Unlock()
+	for uid, list := range stored {
+		for _, rec := range list {
+			f := rec.CalendarFeed
+			f.ETag, f.LastModified = rec.ETag, rec.LastModified
+			calendarFeeds[uid] = append(calendarFeeds[uid], &f)
+			data, err := readStateFile(filepath.Join(feedsDir, f.ID+".ics"))
+			if err != nil {
+				log.Printf("load calendar feed %s failed: %v", f.ID, err)
+				continue
+			}
+			if cal, err := parseFeed(data); err == nil {
+				feedEvents[f.ID] = cal.Events
+			} else {
+				log.Printf("parse calendar feed %s failed: %v", f.ID, err)
+			}
+		}
+	}
+}
+
+// saveCalendarFeedsLocked persists feed metadata; caller must hold feedsMu.
+func saveCalendarFeedsLocked() {
+	stored := map[string][]feedRecord{}
+	for uid, list := range calendarFeeds {
+		for _, f := range list {
+			stored[uid] = append(stored[uid], feedRecord{CalendarFeed: *f, ETag: f.ETag, LastModified: f.LastModified})
+		}
+	}
+	if err := saveDataFile(calendarFeedsFile, stored); err != nil {
+		log.Printf("save calendar feeds failed: %v", err)
+	}
+}
+
+// parseFeed parses ICS data and folds RECURRENCE-ID overrides into their
+// series: the overridden occurrence is excluded from the master and the
+// override is kept as a standalone event.
+func parseFeed(data []byte) (*ical.Calendar, error) {
+	cal, err := ical.Parse(bytes.NewReader(data), academicLoc)
+	if err != nil {
+		return nil, err
+	}
+	masters := map[string]int{}
+	for i, e := range cal.Events {
+		if _, ok := e.Prop("RECURRENCE-ID"); !ok && e.RRule != "" {
+			masters[e.UID] = i
+		}
+	}
+	for _, e := range cal.Events {
+		p, ok := e.Prop("RECURRENCE-ID")
+		if !ok {
+			continue
+		}
+		i, ok := masters[e.UID]
+		if !ok {
+			continue
+		}
+		if t, _, err := ical.ParseTime(p, academicLoc); err == nil {
+			cal.Events[i].ExDates = append(cal.Events[i].ExDates, t)
+		}
+	}
+	return cal, nil
+}
+
+// storeFeedData validates and keeps a new copy of a feed's ICS. The file
+// is written under feedsMu, so it cannot land after a DELETE removed it.
+func storeFeedData(f *CalendarFeed, data []byte) error {
+	cal, err := parseFeed(data)
+	if err != nil {
+		return err
+	}
+	feedsMu.Lock()
+	defer feedsMu.Unlock()
+	if f.deleted {
+		return errFeedDeleted
+	}
+	if err := writeDataFile(filepath.Join(feedsDir, f.ID+".ics"), data); err != nil {
+		return err
+	}
+	feedEvents[f.ID] = cal.Events
+	f.EventCount = len(cal.Events)
+	if f.Name == "" {
+		f.Name = cal.Name
+	}
+	return nil
+}
+
+// handleCalendarFeeds manages the imported calendars of the session user.
+// GET    -> { calendars: [...] }
+// POST   JSON { name, url } mirrors a URL -> 201 { calendar }
+// POST   ?name=... with a text/calendar body or multipart field "file" uploads a file -> 201 { calendar }
+// DELETE ?id=... -> 204
+func handleCalendarFeeds(w http.ResponseWriter, r *http.Request) {
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+
+	switch r.Method {
+	case http.MethodGet:
+		feedsMu.RLock()
+		list := []CalendarFeed{}
+		for _, f := range calendarFeeds[sess.UID] {
+			list = append(list, *f)
+		}
+		feedsMu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"calendars": list,
+		})
+
+	case http.MethodPost:
+		feedsMu.RLock()
+		n := len(calendarFeeds[sess.UID])
+		feedsMu.RUnlock()
+		if n >= maxFeedsPerUser {
+			http.Error(w, fmt.Sprintf("at most %d calendars", maxFeedsPerUser), http.StatusConflict)
+			return
+		}
+		id, err := genToken()
+		if err != nil {
+			http.Error(w, "create calendar id failed", http.StatusInternalServerError)
+			return
+		}
+		f := &CalendarFeed{ID: id[:12], Name: strings.TrimSpace(r.URL.Query().Get("name")), CreatedAt: clock()}
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/json":
+			var body struct {
+				Name string `json:"name"`
+				URL  string `json:"url"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "invalid json body", http.StatusBadRequest)
+				return
+			}
+			u, err := url.Parse(strings.TrimSpace(body.URL))
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "webcal") || u.Host == "" {
+				http.Error(w, "url must be an absolute http(s) or webcal url", http.StatusBadRequest)
+				return
+			}
+			if u.Scheme == "webcal" {
+				u.Scheme = "https"
+			}
+			f.URL = u.String()
+			if body.Name != "" {
+				f.Name = strings.TrimSpace(body.Name)
+			}
+			if err := refreshFeed(f); err != nil {
+				http.Error(w, "fetch calendar failed: "+err.Error(), http.StatusBadGateway)
+				return
+			}
+		default:
+			data, err := readUploadedCalendar(r, mediaType)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := storeFeedData(f, data); err != nil {
+				http.Error(w, "invalid calendar: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			f.LastFetched = clock()
+		}
+		if f.Name == "" {
+			f.Name = "calendar-" + f.ID[:6]
+		}
+
+		feedsMu.Lock()
+		calendarFeeds[sess.UID] = append(calendarFeeds[sess.UID], f)
+		saveCalendarFeedsLocked()
+		out := *f
+		feedsMu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"calendar": out,
+		})
+
+	case http.MethodDelete:
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if id == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		feedsMu.Lock()
+		list := calendarFeeds[sess.UID]
+		found := false
+		for i, f := range list {
+			if f.ID == id {
+				calendarFeeds[sess.UID] = append(list[:i:i], list[i+1:]...)
+				delete(feedEvents, id)
+				f.deleted = true
+				found = true
+				break
+			}
+		}
+		if found {
+			saveCalendarFeedsLocked()
+		}
+		feedsMu.Unlock()
+		if !found {
+			http.Error(w, "calendar not found", http.StatusNotFound)
+			return
+		}
+		removeDataFile(filepath.Join(feedsDir, id+".ics"))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleCalendarFeedRefresh re-fetches one mirrored calendar now.
+// POST /events/calendars/refresh?id=... -> { calendar }
+func handleCalendarFeedRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	var f *CalendarFeed
+	feedsMu.RLock()
+	for _, cand := range calendarFeeds[sess.UID] {
+		if cand.ID == id {
+			f = cand
+		}
+	}
+	feedsMu.RUnlock()
+	if f == nil {
+		http.Error(w, "calendar not found", http.StatusNotFound)
+		return
+	}
+	if f.URL == "" {
+		http.Error(w, "uploaded calendars cannot be refreshed", http.StatusBadRequest)
+		return
+	}
+	err := refreshFeed(f)
+	feedsMu.Lock()
+	saveCalendarFeedsLocked()
+	out := *f
+	feedsMu.Unlock()
+	if errors.Is(err, errFeedDeleted) {
+		http.Error(w, "calendar not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "fetch calendar failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"calendar": out,
+	})
+}
+
+// readUploadedCalendar returns the ICS of a raw or multipart upload.
+func readUploadedCalendar(r *http.Request, mediaType string) ([]byte, error) {
+	body := io.Reader(r.Body)
+	if mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("multipart field \"file\" is required")
+		}
+		defer file.Close()
+		body = file
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxCalendarUpload+1))
+	if err != nil {
+		return nil, fmt.Errorf("read upload failed")
+	}
+	if len(data) > maxCalendarUpload {
+		return nil, fmt.Errorf("calendar too large")
+	}
+	return data, nil
+}
+
+// refreshFeed downloads a mirrored feed, honouring ETag/Last-Modified.
+// The outcome is recorded on f; the caller persists metadata.
+func refreshFeed(f *CalendarFeed) error {
+	err := fetchFeed(f)
+	feedsMu.Lock()
+	f.LastFetched = clock()
+	f.LastError = ""
+	if err != nil {
+		f.LastError = err.Error()
+	}
+	feedsMu.Unlock()
+	return err
+}
+
+func fetchFeed(f *CalendarFeed) error {
+	req, err := http.NewRequest(http.MethodGet, f.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/calendar, */*;q=0.5")
+	feedsMu.RLock()
+	if f.ETag != "" {
+		req.Header.Set("If-None-Match", f.ETag)
+	}
+	if f.LastModified != "" {
+		req.Header.Set("If-Modified-Since", f.LastModified)
+	}
+	feedsMu.RUnlock()
+	// Errors reach the user as lastError; they say what went wrong without
+	// the details (dial errors, status codes) that would make the feed
+	// fetcher a port and host scanner.
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		if errors.Is(err, errNonPublicAddress) {
+			return errFeedAddress
+		}
+		log.Printf("fetch calendar feed %s failed: %v", f.ID, err)
+		return errFeedUnreachable
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("fetch calendar feed %s: server answered %s", f.ID, resp.Status)
+		return errFeedBadStatus
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCalendarUpload+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxCalendarUpload {
+		return fmt.Errorf("calendar too large")
+	}
+	if err := storeFeedData(f, data); err != nil {
+		return err
+	}
+	feedsMu.Lock()
+	f.ETag, f.LastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	feedsMu.Unlock()
+	return nil
+}
+
+// startFeedScheduler refreshes mirrored feeds in the background.
+func startFeedScheduler() {
+	interval := defaultFeedRefreshInterval
+	if v := os.Getenv("FEED_REFRESH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		} else {
+			log.Printf("ignoring invalid FEED_REFRESH_INTERVAL %q", v)
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			refreshAllFeeds()
+		}
+	}()
+}
+
+// refreshAllFeeds re-fetches every mirrored feed one after another.
+func refreshAllFeeds() {
+	feedsMu.RLock()
+	var mirrored []*CalendarFeed
+	for _, list := range calendarFeeds {
+		for _, f := range list {
+			if f.URL != "" {
+				mirrored = append(mirrored, f)
+			}
+		}
+	}
+	feedsMu.RUnlock()
+	if len(mirrored) == 0 {
+		return
+	}
+	for _, f := range mirrored {
+		if err := refreshFeed(f); err != nil {
+			log.Printf("refresh calendar feed %s failed: %v", f.ID, err)
+		}
+	}
+	feedsMu.Lock()
+	saveCalendarFeedsLocked()
+	feedsMu.Unlock()
+}
+
+// feedItems expands the imported calendars of uid overlapping [from, to).
+func feedItems(uid string, from, to time.Time) []scheduleItem {
+	type feedCopy struct {
+		feed   CalendarFeed
+		events []ical.Event
+	}
+	feedsMu.RLock()
+	var feeds []feedCopy
+	for _, f := range calendarFeeds[uid] {
+		feeds = append(feeds, feedCopy{feed: *f, events: feedEvents[f.ID]})
+	}
+	feedsMu.RUnlock()
+
+	var items []scheduleItem
+	for _, fc := range feeds {
+		for _, e := range fc.events {
+			starts, err := e.Occurrences(from, to, academicLoc)
+			if err != nil {
+				// One unsupported RRULE should not hide the rest of the feed.
+				continue
+			}
+			for _, start := range starts {
+				item := scheduleItem{
+					Source:    sourceCalendar,
+					ID:        fc.feed.ID + ":" + e.UID,
+					Title:     e.Summary,
+					Location:  e.Location,
+					Notes:     e.Description,
+					Calendar:  fc.feed.Name,
+					Begin:     start.In(academicLoc),
+					End:       start.Add(e.End.Sub(e.Start)).In(academicLoc),
+					AllDay:    e.AllDay,
+					Recurring: e.RRule != "",
+				}
+				if len(e.Categories) > 0 {
+					item.Category = e.Categories[0]
+				}
+				if _, override := e.Prop("RECURRENCE-ID"); item.Recurring || override {
+					item.ID += "@" + start.Format("20060102T1504")
+				}
+				items = append(items, item)
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Begin.Before(items[j].Begin) })
+	return items
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"LoginTest/models"
+	"LoginTest/render"
+)
+
+// ------------------------------
+// Non-JSON course responses
+// ------------------------------
+// /courses/today, /get_courses, /courses/range and /courses/week answer
+// with CSV, aligned text or Markdown when asked through ?format= or
+// Accept (see render.Negotiate). All of them go through writeDaysTable,
+// one row per schedule entry.
+
+var scheduleColumns = []render.Column{
+	{Key: "date", Label: "日期"},
+	{Key: "weekday", Label: "星期"},
+	{Key: "begin", Label: "开始"},
+	{Key: "end", Label: "结束"},
+	{Key: "title", Label: "课程/日程"},
+	{Key: "teacher", Label: "教师"},
+	{Key: "location", Label: "地点"},
+	{Key: "source", Label: "来源"},
+	{Key: "note", Label: "备注"},
+}
+
+var sourceLabels = map[string]string{sourceCourse: "课程", sourcePersonal: "个人", sourceCalendar: "日历"}
+
+// writeDaysTable renders days in format f with the given status code.
+func writeDaysTable(w http.ResponseWriter, f render.Format, status int, title string, days []dayView) {
+	w.Header().Set("Content-Type", f.ContentType())
+	if f == render.CSV {
+		w.Header().Set("Content-Disposition", `attachment; filename="courses.csv"`)
+	}
+	w.WriteHeader(status)
+	_ = render.Write(w, f, daysTable(title, days))
+}
+
+// writeDayTable renders the courses of one day (YYYYMMDD) of uid. status is
+// the upstream status code, passed on like the JSON responses do, so a
+// failed upstream call is not reported as 200 with an empty table.
+func writeDayTable(w http.ResponseWriter, f render.Format, status int, uid, dateStr string, courses []models.CourseRecord) {
+	day, err := time.ParseInLocation("20060102", dateStr, academicLoc)
+	if err != nil {
+		http.Error(w, "invalid date", http.StatusBadRequest)
+		return
+	}
+	view := newDayView(uid, day, courses)
+	writeDaysTable(w, f, status, view.Date+" "+weekdayLabels[day.Weekday()], []dayView{view})
+}
+
+// daysTable flattens days into a table. Days without entries still get a
+// row so holidays and empty days are visible; day labels and walking
+// warnings go into the note column, the teaching week into the notes
+// above the table.
+func daysTable(title string, days []dayView) render.Table {
+	t := render.Table{Title: title, Columns: scheduleColumns, Rows: [][]string{}}
+	for _, d := range days {
+		if len(days) == 1 && d.Semester != nil && d.TeachingWeek > 0 {
+			t.Notes = append(t.Notes, strings.TrimSpace(fmt.Sprintf("%s 第 %d 教学周", d.Semester.SemesterName, d.TeachingWeek)))
+		}
+		warnings := map[string]string{}
+		for _, tr := range d.Transitions {
+			if tr.Warning != "" {
+				warnings[tr.ToID] = tr.Warning
+			}
+		}
+		weekday := weekdayLabels[d.WeekDay%7]
+		dayNote := ""
+		if d.Calendar != nil {
+			dayNote = d.Calendar.Label
+		}
+		if len(d.Schedule) == 0 {
+			if dayNote == "" {
+				dayNote = "无课程"
+			}
+			t.Rows = append(t.Rows, []string{d.Date, weekday, "", "", "", "", "", "", dayNote})
+			continue
+		}
+		for i, it := range d.Schedule {
+			begin, end := clockOf(it.Begin), clockOf(it.End)
+			if it.AllDay {
+				begin, end = "全天", ""
+			}
+			var notes []string
+			if i == 0 && dayNote != "" {
+				notes = append(notes, dayNote)
+			}
+			if it.Category != "" {
+				notes = append(notes, it.Category)
+			}
+			if w := warnings[it.ID]; w != "" {
+				notes = append(notes, w)
+			}
+			t.Rows = append(t.Rows, []string{
+				d.Date, weekday, begin, end, it.Title, it.Teacher, it.Location,
+				sourceLabels[it.Source], strings.Join(notes, "；"),
+			})
+		}
+	}
+	return t
+}
+
+func clockOf(t time.Time) string {
+	return t.In(academicLoc).Format("15:04")
+}
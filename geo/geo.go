@@ -0,0 +1,30 @@
+// Package geo has the little geometry the server needs: great-circle
+// distances between WGS 84 coordinates.
+package geo
+
+import "math"
+
+// earthRadius is the mean Earth radius in metres.
+const earthRadius = 6371008.8
+
+// Point is a WGS 84 coordinate in degrees.
+type Point struct {
+	Lat float64 `json:"latitude"`
+	Lon float64 `json:"longitude"`
+}
+
+// Valid reports whether p is inside the coordinate ranges and not the
+// (0, 0) placeholder upstream uses for "unknown".
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180 && (p.Lat != 0 || p.Lon != 0)
+}
+
+// Distance returns the haversine distance between a and b in metres.
+func Distance(a, b Point) float64 {
+	rad := math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLon := (b.Lon - a.Lon) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
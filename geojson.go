@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"LoginTest/geo"
+	"LoginTest/models"
+)
+
+// ------------------------------
+// Classroom map (GeoJSON)
+// ------------------------------
+// /courses/map.geojson turns the cached timetable of the session user into
+// a FeatureCollection with one Point per building and per classroom. Every
+// feature lists the courses held there, so map apps can show "what do I
+// have in this building". Coordinates come from the upstream classroom
+// fields or, when missing, the campus building table (see walking.go);
+// places without any coordinates are left out and counted in
+// "skipped".
+
+type geoJSONCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+	Skipped  int              `json:"skipped"`
+}
+
+type geoJSONFeature struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id"`
+	Geometry   geoJSONPoint   `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"` // longitude, latitude
+}
+
+// mapCourse is one course listed on a feature.
+type mapCourse struct {
+	CourseID    string   `json:"courseId"`
+	CourseName  string   `json:"courseName"`
+	TeacherName string   `json:"teacherName,omitempty"`
+	Sessions    []string `json:"sessions"` // e.g. "周一 08:00-09:35"
+}
+
+// mapPlace accumulates the courses of one building or classroom.
+type mapPlace struct {
+	id, kind                    string
+	building, storey, classroom string
+	points                      []geo.Point
+	courses                     map[string]*mapCourse
+}
+
+var weekdayLabels = [...]string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// handleCoursesMap serves the classroom map of the session user.
+// GET /courses/map.geojson
+func handleCoursesMap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+
+	var records []models.CourseRecord
+	for _, snap := range cachedDays(sess.UID) {
+		records = append(records, snap.Courses.Result...)
+	}
+	w.Header().Set("Content-Type", "application/geo+json")
+	_ = json.NewEncoder(w).Encode(buildCoursesMap(records))
+}
+
+// buildCoursesMap groups records by building and classroom.
+func buildCoursesMap(records []models.CourseRecord) geoJSONCollection {
+	places := map[string]*mapPlace{}
+	skipped := map[string]bool{}
+	place := func(key, kind string, c models.CourseRecord) *mapPlace {
+		p, ok := places[key]
+		if !ok {
+			p = &mapPlace{id: key, kind: kind, building: c.TeachBuildName, courses: map[string]*mapCourse{}}
+			if kind == "classroom" {
+				p.storey, p.classroom = c.StoreyName, c.ClassroomName
+			}
+			places[key] = p
+		}
+		return p
+	}
+
+	for _, c := range records {
+		_, pt, ok := itemPlace(scheduleItem{Course: &c})
+		roomKey := "classroom:" + displayName(c.ClassroomID, c.TeachBuildName+"/"+c.ClassroomName)
+		if !ok {
+			skipped[roomKey] = true
+			continue
+		}
+		session := ""
+		if begin, err := models.ParseCourseTime(c.ClassBeginTime); err == nil {
+			session = weekdayLabels[begin.Weekday()] + " " + begin.Format("15:04")
+			if end, err := models.ParseCourseTime(c.ClassEndTime); err == nil {
+				session += "-" + end.Format("15:04")
+			}
+		}
+		for _, p := range []*mapPlace{
+			place("building:"+displayName(c.TeachBuildID, c.TeachBuildName), "building", c),
+			place(roomKey, "classroom", c),
+		} {
+			p.points = append(p.points, pt)
+			key := displayName(c.CourseID, c.CourseName)
+			mc, ok := p.courses[key]
+			if !ok {
+				mc = &mapCourse{CourseID: c.CourseID, CourseName: c.CourseName, TeacherName: c.TeacherName, Sessions: []string{}}
+				p.courses[key] = mc
+			}
+			if session != "" && !slices.Contains(mc.Sessions, session) {
+				mc.Sessions = append(mc.Sessions, session)
+			}
+		}
+	}
+
+	fc := geoJSONCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}, Skipped: len(skipped)}
+	for _, p := range places {
+		fc.Features = append(fc.Features, p.feature())
+	}
+	// "building:" sorts before "classroom:".
+	sort.Slice(fc.Features, func(i, j int) bool { return fc.Features[i].ID < fc.Features[j].ID })
+	return fc
+}
+
+// feature places p at the mean of its coordinates.
+func (p *mapPlace) feature() geoJSONFeature {
+	var lat, lon float64
+	for _, pt := range p.points {
+		lat += pt.Lat
+		lon += pt.Lon
+	}
+	n := float64(len(p.points))
+	courses := make([]*mapCourse, 0, len(p.courses))
+	for _, c := range p.courses {
+		sort.Strings(c.Sessions)
+		courses = append(courses, c)
+	}
+	sort.Slice(courses, func(i, j int) bool { return courses[i].CourseName < courses[j].CourseName })
+
+	props := map[string]any{
+		"kind":     p.kind,
+		"building": p.building,
+		"courses":  courses,
+	}
+	name := p.building
+	if p.kind == "classroom" {
+		props["storey"] = p.storey
+		props["classroom"] = p.classroom
+		name = strings.TrimSpace(fmt.Sprintf("%s %s", p.building, p.classroom))
+	}
+	props["name"] = name
+	return geoJSONFeature{
+		Type:       "Feature",
+		ID:         p.id,
+		Geometry:   geoJSONPoint{Type: "Point", Coordinates: [2]float64{lon / n, lat / n}},
+		Properties: props,
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"unicode/utf8"
+
+	"LoginTest/events"
+)
+
+// ------------------------------
+// Extension hooks
+// ------------------------------
+// Integrations add side effects without touching the handlers: implement
+// events.Hook in a new file of this package and register it from init:
+//
+//	func init() { bus.Register(myHook{}) }
+//
+// Every published event is then delivered to it; events/types.go lists
+// them (LoginSucceeded, LoginFailed, CoursesFetched, CourseChanged,
+// SignAttempted, SessionExpired, ScheduleConflict).
+
+func init() {
+	// EVENT_LOG=1 logs every event, handy while writing a hook.
+	if os.Getenv("EVENT_LOG") != "" {
+		bus.Register(events.HookFunc{HookName: "log", Fn: logEvent})
+	}
+}
+
+// logEvent prints an event without the bulky course lists.
+func logEvent(ev events.Event) {
+	if fetched, ok := ev.Data.(events.CoursesFetched); ok {
+		log.Printf("event #%d %s user=%s date=%s total=%d", ev.ID, ev.Type, maskID(ev.UID), fetched.Date, fetched.Total)
+		return
+	}
+	data, _ := json.Marshal(ev.Data)
+	log.Printf("event #%d %s user=%s %s", ev.ID, ev.Type, maskID(ev.UID), data)
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 rune.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
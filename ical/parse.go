@@ -0,0 +1,278 @@
+// Package ical reads the parts of RFC 5545 iCalendar files the server
+// needs: VEVENT components with their dates, summary, location,
+// categories and recurrence rule.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Property is one content line, e.g. DTSTART;TZID=Asia/Shanghai:20261001T080000.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Event is a VEVENT.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Categories  []string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	RRule       string
+	ExDates     []time.Time
+	// Props keeps every property, including X- extensions.
+	Props []Property
+}
+
+// Prop returns the first property called name (upper case), if any.
+func (e Event) Prop(name string) (Property, bool) {
+	for _, p := range e.Props {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// Calendar is a parsed VCALENDAR.
+type Calendar struct {
+	Name   string // X-WR-CALNAME
+	Events []Event
+}
+
+// Parse reads an iCalendar stream. Times without TZID or Z suffix are
+// interpreted in loc.
+func Parse(r io.Reader, loc *time.Location) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	cal := &Calendar{}
+	var cur *Event
+	depth := 0
+	sawCalendar := false
+	for n, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("ical: line %d: %w", n+1, err)
+		}
+		switch p.Name {
+		case "BEGIN":
+			depth++
+			switch strings.ToUpper(p.Value) {
+			case "VCALENDAR":
+				sawCalendar = true
+			case "VEVENT":
+				cur = &Event{}
+			}
+			continue
+		case "END":
+			depth--
+			if strings.ToUpper(p.Value) == "VEVENT" && cur != nil {
+				if cur.End.IsZero() {
+					cur.End = defaultEnd(*cur)
+				}
+				cal.Events = append(cal.Events, *cur)
+				cur = nil
+			}
+			continue
+		}
+		if cur == nil {
+			if p.Name == "X-WR-CALNAME" {
+				cal.Name = unescape(p.Value)
+			}
+			continue
+		}
+		cur.Props = append(cur.Props, p)
+		if err := cur.apply(p, loc); err != nil {
+			return nil, fmt.Errorf("ical: line %d: %w", n+1, err)
+		}
+	}
+	if !sawCalendar {
+		return nil, fmt.Errorf("ical: missing BEGIN:VCALENDAR")
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("ical: unbalanced BEGIN/END")
+	}
+	return cal, nil
+}
+
+func (e *Event) apply(p Property, loc *time.Location) error {
+	var err error
+	switch p.Name {
+	case "UID":
+		e.UID = p.Value
+	case "SUMMARY":
+		e.Summary = unescape(p.Value)
+	case "DESCRIPTION":
+		e.Description = unescape(p.Value)
+	case "LOCATION":
+		e.Location = unescape(p.Value)
+	case "CATEGORIES":
+		for _, c := range strings.Split(p.Value, ",") {
+			if c = strings.TrimSpace(unescape(c)); c != "" {
+				e.Categories = append(e.Categories, c)
+			}
+		}
+	case "DTSTART":
+		e.Start, e.AllDay, err = ParseTime(p, loc)
+	case "DTEND":
+		e.End, _, err = ParseTime(p, loc)
+	case "DURATION":
+		var d time.Duration
+		if d, err = ParseDuration(p.Value); err == nil && !e.Start.IsZero() {
+			e.End = e.Start.Add(d)
+		}
+	case "RRULE":
+		e.RRule = p.Value
+	case "EXDATE":
+		for _, v := range strings.Split(p.Value, ",") {
+			t, _, perr := ParseTime(Property{Name: p.Name, Params: p.Params, Value: v}, loc)
+			if perr != nil {
+				return perr
+			}
+			e.ExDates = append(e.ExDates, t)
+		}
+	}
+	return err
+}
+
+// defaultEnd follows RFC 5545: an all-day event lasts one day, a timed
+// event without DTEND/DURATION has no length.
+func defaultEnd(e Event) time.Time {
+	if e.AllDay {
+		return e.Start.AddDate(0, 0, 1)
+	}
+	return e.Start
+}
+
+// ParseTime parses a DATE or DATE-TIME property value.
+func ParseTime(p Property, loc *time.Location) (t time.Time, allDay bool, err error) {
+	v := strings.TrimSpace(p.Value)
+	if tzid, ok := p.Params["TZID"]; ok {
+		if l, lerr := time.LoadLocation(strings.Trim(tzid, `"`)); lerr == nil {
+			loc = l
+		}
+	}
+	if p.Params["VALUE"] == "DATE" || len(v) == 8 {
+		t, err = time.ParseInLocation("20060102", v, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err = time.Parse("20060102T150405Z", v)
+		return t.In(loc), false, err
+	}
+	t, err = time.ParseInLocation("20060102T150405", v, loc)
+	return t, false, err
+}
+
+// ParseDuration parses an RFC 5545 duration such as PT1H30M or P1D.
+func ParseDuration(v string) (time.Duration, error) {
+	s := strings.ToUpper(strings.TrimSpace(v))
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("invalid duration %q", v)
+	}
+	s = s[1:]
+	var d time.Duration
+	inTime := false
+	num := 0
+	digits := false
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			num = num*10 + int(r-'0')
+			digits = true
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		}
+		if !digits {
+			return 0, fmt.Errorf("invalid duration %q", v)
+		}
+		switch {
+		case r == 'W':
+			d += time.Duration(num) * 7 * 24 * time.Hour
+		case r == 'D':
+			d += time.Duration(num) * 24 * time.Hour
+		case r == 'H' && inTime:
+			d += time.Duration(num) * time.Hour
+		case r == 'M' && inTime:
+			d += time.Duration(num) * time.Minute
+		case r == 'S' && inTime:
+			d += time.Duration(num) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", v)
+		}
+		num, digits = 0, false
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// unfold joins continuation lines (those starting with space or tab).
+func unfold(r io.Reader) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, sc.Err()
+}
+
+// parseLine splits NAME;PARAM=VALUE;...:VALUE, honouring quoted params.
+func parseLine(line string) (Property, error) {
+	p := Property{Params: map[string]string{}}
+	inQuote := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		}
+		if r == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return p, fmt.Errorf("missing ':' in %q", line)
+	}
+	head := line[:colon]
+	p.Value = line[colon+1:]
+	parts := strings.Split(head, ";")
+	p.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		k, v, _ := strings.Cut(param, "=")
+		p.Params[strings.ToUpper(k)] = v
+	}
+	return p, nil
+}
+
+// unescape reverses TEXT escaping.
+func unescape(s string) string {
+	r := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return r.Replace(s)
+}
@@ -0,0 +1,411 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPeriods bounds the expansion loop of one call. It is one 400-year
+// Gregorian cycle of days, after which every rule repeats its pattern;
+// Between normally stops long before, at the first period after to.
+const maxPeriods = 146097
+
+// WeekdayNum is one BYDAY entry; N is the ordinal inside the month or year
+// (1 = first, -1 = last), 0 means every such weekday.
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// RRule is a recurrence rule. Supported: FREQ=DAILY/WEEKLY/MONTHLY/YEARLY,
+// INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST=MO.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+var dayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// ParseRRule parses the value of an RRULE property. A floating UNTIL is
+// read in loc.
+func ParseRRule(s string, loc *time.Location) (*RRule, error) {
+	r := &RRule{Interval: 1}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("rrule: invalid part %q", part)
+		}
+		k, v = strings.ToUpper(k), strings.ToUpper(v)
+		var err error
+		switch k {
+		case "FREQ":
+			switch v {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				r.Freq = v
+			default:
+				return nil, fmt.Errorf("rrule: unsupported FREQ %q", v)
+			}
+		case "INTERVAL":
+			if r.Interval, err = strconv.Atoi(v); err != nil || r.Interval < 1 {
+				return nil, fmt.Errorf("rrule: invalid INTERVAL %q", v)
+			}
+		case "COUNT":
+			if r.Count, err = strconv.Atoi(v); err != nil || r.Count < 1 {
+				return nil, fmt.Errorf("rrule: invalid COUNT %q", v)
+			}
+		case "UNTIL":
+			var date bool
+			if r.Until, date, err = ParseTime(Property{Name: "UNTIL", Value: v}, loc); err != nil {
+				return nil, fmt.Errorf("rrule: invalid UNTIL %q", v)
+			}
+			if date {
+				// A date-only UNTIL includes the whole day.
+				r.Until = r.Until.AddDate(0, 0, 1).Add(-time.Second)
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(v, ",") {
+				wd, ok := dayCodes[d[max(len(d)-2, 0):]]
+				if !ok {
+					return nil, fmt.Errorf("rrule: invalid BYDAY %q", d)
+				}
+				n := 0
+				if prefix := d[:len(d)-2]; prefix != "" {
+					if n, err = strconv.Atoi(prefix); err != nil || n == 0 || n < -53 || n > 53 {
+						return nil, fmt.Errorf("rrule: invalid BYDAY %q", d)
+					}
+				}
+				r.ByDay = append(r.ByDay, WeekdayNum{N: n, Day: wd})
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(v, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("rrule: invalid BYMONTHDAY %q", d)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, m := range strings.Split(v, ",") {
+				n, err := strconv.Atoi(m)
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("rrule: invalid BYMONTH %q", m)
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			if v != "MO" {
+				return nil, fmt.Errorf("rrule: only WKST=MO is supported")
+			}
+		default:
+			return nil, fmt.Errorf("rrule: unsupported part %s", k)
+		}
+	}
+	if r.Freq == "" {
+		return nil, fmt.Errorf("rrule: FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("rrule: COUNT and UNTIL are exclusive")
+	}
+	return r, nil
+}
+
+// String formats the rule back to RRULE syntax.
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, d := range r.ByDay {
+			code := strings.ToUpper(d.Day.String()[:2])
+			if d.N != 0 {
+				code = strconv.Itoa(d.N) + code
+			}
+			days = append(days, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		var days []string
+		for _, d := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(d))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		var months []string
+		for _, m := range r.ByMonth {
+			months = append(months, strconv.Itoa(int(m)))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Between returns the start times of the occurrences of a series starting
+// at dtstart that begin in [from, to). exdates are skipped but still count
+// towards COUNT, as RFC 5545 specifies.
+func (r *RRule) Between(dtstart, from, to time.Time, exdates []time.Time) []time.Time {
+	excluded := map[int64]bool{}
+	for _, t := range exdates {
+		excluded[t.Unix()] = true
+	}
+	var out []time.Time
+	r.each(dtstart, from, to, func(t time.Time) {
+		if !t.Before(from) && !excluded[t.Unix()] {
+			out = append(out, t)
+		}
+	})
+	return out
+}
+
+// Occurs reports whether a series starting at dtstart has any occurrence.
+// Rules whose BY parts never meet (FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30) or
+// whose UNTIL precedes dtstart do not. The calendar repeats every 400
+// years, so looking that many periods ahead is enough (capped at 10000
+// years for huge intervals).
+func (r *RRule) Occurs(dtstart time.Time) bool {
+	found := false
+	r.each(dtstart, dtstart, dtstart.AddDate(400*min(r.Interval, 25), 0, 0), func(time.Time) {
+		found = true
+	})
+	return found
+}
+
+// each calls fn with the occurrences of the series starting at dtstart in
+// order, from the period containing from until the first one at or after
+// to. Without COUNT the periods before from are skipped; with COUNT they
+// have to be walked to count their occurrences.
+func (r *RRule) each(dtstart, from, to time.Time, fn func(time.Time)) {
+	first, seen := 0, 0
+	if r.Count == 0 {
+		first = r.periodOf(dtstart, from)
+	}
+	for period := first; period < first+maxPeriods; period++ {
+		if !r.periodStart(dtstart, period).Before(to) {
+			return
+		}
+		for _, t := range r.candidates(dtstart, period) {
+			if t.Before(dtstart) {
+				continue
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return
+			}
+			if r.Count > 0 && seen >= r.Count {
+				return
+			}
+			seen++
+			if !t.Before(to) {
+				return
+			}
+			fn(t)
+		}
+	}
+}
+
+// periodStart is midnight of the first day of the n-th period.
+func (r *RRule) periodStart(dtstart time.Time, n int) time.Time {
+	y, m, d := dtstart.Date()
+	loc := dtstart.Location()
+	switch r.Freq {
+	case "DAILY":
+		return time.Date(y, m, d+n*r.Interval, 0, 0, 0, 0, loc)
+	case "WEEKLY":
+		return time.Date(y, m, d-(int(dtstart.Weekday())+6)%7+7*n*r.Interval, 0, 0, 0, 0, loc)
+	case "MONTHLY":
+		return time.Date(y, m+time.Month(n*r.Interval), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y+n*r.Interval, time.January, 1, 0, 0, 0, 0, loc)
+	}
+}
+
+// periodOf is the number of the period containing t, 0 if t precedes
+// dtstart.
+func (r *RRule) periodOf(dtstart, t time.Time) int {
+	t = t.In(dtstart.Location())
+	if !t.After(dtstart) {
+		return 0
+	}
+	var n int
+	switch r.Freq {
+	case "DAILY":
+		n = (dayNumber(t) - dayNumber(dtstart)) / r.Interval
+	case "WEEKLY":
+		monday := dayNumber(dtstart) - (int(dtstart.Weekday())+6)%7
+		n = (dayNumber(t) - monday) / (7 * r.Interval)
+	case "MONTHLY":
+		n = (t.Year()*12 + int(t.Month()) - dtstart.Year()*12 - int(dtstart.Month())) / r.Interval
+	default:
+		n = (t.Year() - dtstart.Year()) / r.Interval
+	}
+	return n
+}
+
+// dayNumber counts calendar days, ignoring the clock and DST.
+func dayNumber(t time.Time) int {
+	y, m, d := t.Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// candidates lists the instances of the n-th period, in order.
+func (r *RRule) candidates(dtstart time.Time, n int) []time.Time {
+	loc := dtstart.Location()
+	h, m, s := dtstart.Clock()
+	at := func(y int, mon time.Month, d int) time.Time {
+		return time.Date(y, mon, d, h, m, s, 0, loc)
+	}
+	var out []time.Time
+	switch r.Freq {
+	case "DAILY":
+		t := dtstart.AddDate(0, 0, n*r.Interval)
+		if r.matchDay(t) && r.matchMonth(t.Month()) && r.matchMonthDay(t) {
+			out = append(out, t)
+		}
+	case "WEEKLY":
+		monday := dtstart.AddDate(0, 0, -((int(dtstart.Weekday())+6)%7)+7*n*r.Interval)
+		for i := 0; i < 7; i++ {
+			t := at(monday.Year(), monday.Month(), monday.Day()+i)
+			if len(r.ByDay) == 0 && t.Weekday() != dtstart.Weekday() {
+				continue
+			}
+			if r.matchDay(t) && r.matchMonth(t.Month()) {
+				out = append(out, t)
+			}
+		}
+	case "MONTHLY":
+		first := time.Date(dtstart.Year(), dtstart.Month()+time.Month(n*r.Interval), 1, 0, 0, 0, 0, loc)
+		if r.matchMonth(first.Month()) {
+			out = r.monthDays(first, dtstart, at)
+		}
+	case "YEARLY":
+		year := dtstart.Year() + n*r.Interval
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{dtstart.Month()}
+		}
+		for _, mon := range months {
+			out = append(out, r.monthDays(time.Date(year, mon, 1, 0, 0, 0, 0, loc), dtstart, at)...)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out
+}
+
+// monthDays expands BYMONTHDAY/BYDAY inside the month starting at first;
+// without either it repeats the day of month of dtstart.
+func (r *RRule) monthDays(first, dtstart time.Time, at func(int, time.Month, int) time.Time) []time.Time {
+	last := first.AddDate(0, 1, -1).Day()
+	var out []time.Time
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, d := range r.ByMonthDay {
+			if d < 0 {
+				d = last + d + 1
+			}
+			if d >= 1 && d <= last {
+				if t := at(first.Year(), first.Month(), d); r.matchDay(t) {
+					out = append(out, t)
+				}
+			}
+		}
+	case len(r.ByDay) > 0:
+		for d := 1; d <= last; d++ {
+			t := at(first.Year(), first.Month(), d)
+			for _, bd := range r.ByDay {
+				if bd.Day != t.Weekday() {
+					continue
+				}
+				nth, nthLast := (d-1)/7+1, -((last-d)/7 + 1)
+				if bd.N == 0 || bd.N == nth || bd.N == nthLast {
+					out = append(out, t)
+					break
+				}
+			}
+		}
+	default:
+		if dtstart.Day() <= last {
+			out = append(out, at(first.Year(), first.Month(), dtstart.Day()))
+		}
+	}
+	return out
+}
+
+func (r *RRule) matchDay(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d.Day == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchMonth(m time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, bm := range r.ByMonth {
+		if bm == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	for _, d := range r.ByMonthDay {
+		if d == t.Day() || d < 0 && last+d+1 == t.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// Occurrences expands e between from and to (start times in [from, to)),
+// or returns e's own start when it does not recur. Occurrences that began
+// before from but are still running are included.
+func (e Event) Occurrences(from, to time.Time, loc *time.Location) ([]time.Time, error) {
+	dur := e.End.Sub(e.Start)
+	if e.RRule == "" {
+		if e.Start.Before(to) && (e.Start.Add(dur).After(from) || !e.Start.Before(from)) {
+			return []time.Time{e.Start}, nil
+		}
+		return nil, nil
+	}
+	rule, err := ParseRRule(e.RRule, loc)
+	if err != nil {
+		return nil, err
+	}
+	if dur > 0 {
+		from = from.Add(-dur + time.Nanosecond)
+	}
+	return rule.Between(e.Start, from, to, e.ExDates), nil
+}
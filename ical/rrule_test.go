@@ -0,0 +1,161 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var shanghai = time.FixedZone("CST", 8*3600)
+
+// at is 08:00 on the given day of 2026 in Shanghai.
+func at(month time.Month, day int) time.Time {
+	return time.Date(2026, month, day, 8, 0, 0, 0, shanghai)
+}
+
+func TestRRuleBetween(t *testing.T) {
+	// Monday 2 March 2026, 08:00.
+	dtstart := at(time.March, 2)
+	tests := []struct {
+		name     string
+		rule     string
+		from, to time.Time // zero: dtstart and 90 days later
+		exdates  []time.Time
+		want     []time.Time
+	}{
+		{
+			name: "weekly BYDAY with COUNT",
+			rule: "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4",
+			want: []time.Time{at(3, 2), at(3, 4), at(3, 9), at(3, 11)},
+		},
+		{
+			name: "date-only UNTIL includes the whole day",
+			rule: "FREQ=WEEKLY;BYDAY=MO;UNTIL=20260316",
+			want: []time.Time{at(3, 2), at(3, 9), at(3, 16)},
+		},
+		{
+			name: "UNTIL just before the last start excludes it",
+			rule: "FREQ=WEEKLY;BYDAY=MO;UNTIL=20260316T075959",
+			want: []time.Time{at(3, 2), at(3, 9)},
+		},
+		{
+			name: "UTC UNTIL",
+			rule: "FREQ=DAILY;UNTIL=20260304T000000Z",
+			want: []time.Time{at(3, 2), at(3, 3), at(3, 4)},
+		},
+		{
+			name:    "EXDATE is skipped but counts towards COUNT",
+			rule:    "FREQ=DAILY;COUNT=5",
+			exdates: []time.Time{at(3, 3)},
+			want:    []time.Time{at(3, 2), at(3, 4), at(3, 5), at(3, 6)},
+		},
+		{
+			name:    "EXDATE in another zone matches the same instant",
+			rule:    "FREQ=DAILY;COUNT=2",
+			exdates: []time.Time{at(3, 3).UTC()},
+			want:    []time.Time{at(3, 2)},
+		},
+		{
+			name: "COUNT is counted from dtstart, not from",
+			rule: "FREQ=DAILY;COUNT=3",
+			from: at(3, 3),
+			want: []time.Time{at(3, 3), at(3, 4)},
+		},
+		{
+			name: "biweekly BYDAY",
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH",
+			to:   at(3, 20),
+			want: []time.Time{at(3, 3), at(3, 5), at(3, 17), at(3, 19)},
+		},
+		{
+			name: "last Friday of the month",
+			rule: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			want: []time.Time{at(3, 27), at(4, 24), at(5, 29)},
+		},
+		{
+			name: "second Tuesday of the month",
+			rule: "FREQ=MONTHLY;BYDAY=2TU",
+			to:   at(5, 1),
+			want: []time.Time{at(3, 10), at(4, 14)},
+		},
+		{
+			name: "BYMONTHDAY skips short months",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3",
+			to:   at(12, 1),
+			want: []time.Time{at(3, 31), at(5, 31), at(7, 31)},
+		},
+		{
+			name: "to is exclusive",
+			rule: "FREQ=DAILY",
+			to:   at(3, 4),
+			want: []time.Time{at(3, 2), at(3, 3)},
+		},
+		{
+			name: "UNTIL before dtstart",
+			rule: "FREQ=DAILY;UNTIL=20260301",
+		},
+		{
+			name: "BY parts that never meet",
+			rule: "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+		},
+	}
+	for _, tt := range tests {
+		r, err := ParseRRule(tt.rule, shanghai)
+		if err != nil {
+			t.Errorf("%s: ParseRRule(%q): %v", tt.name, tt.rule, err)
+			continue
+		}
+		from, to := tt.from, tt.to
+		if from.IsZero() {
+			from = dtstart
+		}
+		if to.IsZero() {
+			to = dtstart.AddDate(0, 0, 90)
+		}
+		got := r.Between(dtstart, from, to, tt.exdates)
+		if !sameTimes(got, tt.want) {
+			t.Errorf("%s: Between = %v, want %v", tt.name, got, tt.want)
+		}
+		if occurs := r.Occurs(dtstart); occurs != (len(tt.want) > 0) {
+			t.Errorf("%s: Occurs = %v", tt.name, occurs)
+		}
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	tests := []struct {
+		rule, want string
+	}{
+		{"BYDAY=MO", "FREQ is required"},
+		{"FREQ=HOURLY", "unsupported FREQ"},
+		{"FREQ=DAILY;COUNT=0", "invalid COUNT"},
+		{"FREQ=DAILY;INTERVAL=-1", "invalid INTERVAL"},
+		{"FREQ=DAILY;COUNT=2;UNTIL=20260310", "exclusive"},
+		{"FREQ=DAILY;UNTIL=tomorrow", "invalid UNTIL"},
+		{"FREQ=WEEKLY;BYDAY=XX", "invalid BYDAY"},
+		{"FREQ=MONTHLY;BYDAY=0MO", "invalid BYDAY"},
+		{"FREQ=MONTHLY;BYDAY=54MO", "invalid BYDAY"},
+		{"FREQ=MONTHLY;BYMONTHDAY=32", "invalid BYMONTHDAY"},
+		{"FREQ=YEARLY;BYMONTH=13", "invalid BYMONTH"},
+		{"FREQ=WEEKLY;WKST=SU", "WKST=MO"},
+		{"FREQ=DAILY;BYSETPOS=1", "unsupported part"},
+	}
+	for _, tt := range tests {
+		_, err := ParseRRule(tt.rule, shanghai)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseRRule(%q) error = %v, want %q", tt.rule, err, tt.want)
+		}
+	}
+}
+
+func sameTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
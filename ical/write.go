@@ -0,0 +1,101 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Writer emits a VCALENDAR. Call Close to write the trailer.
+type Writer struct {
+	w     *bufio.Writer
+	stamp time.Time
+	err   error
+}
+
+// NewWriter starts a calendar named name (X-WR-CALNAME, may be empty).
+func NewWriter(w io.Writer, prodID, name string) *Writer {
+	cw := &Writer{w: bufio.NewWriter(w), stamp: time.Now().UTC()}
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", prodID)
+	cw.line("CALSCALE", "GREGORIAN")
+	if name != "" {
+		cw.line("X-WR-CALNAME", Escape(name))
+	}
+	return cw
+}
+
+// WriteEvent emits e. Timed events are written in UTC; Props with an X-
+// name are copied verbatim.
+func (cw *Writer) WriteEvent(e Event) {
+	cw.line("BEGIN", "VEVENT")
+	cw.line("UID", e.UID)
+	cw.line("DTSTAMP", cw.stamp.Format("20060102T150405Z"))
+	if e.AllDay {
+		cw.line("DTSTART;VALUE=DATE", e.Start.Format("20060102"))
+		cw.line("DTEND;VALUE=DATE", e.End.Format("20060102"))
+	} else {
+		cw.line("DTSTART", e.Start.UTC().Format("20060102T150405Z"))
+		cw.line("DTEND", e.End.UTC().Format("20060102T150405Z"))
+	}
+	cw.line("SUMMARY", Escape(e.Summary))
+	if e.Location != "" {
+		cw.line("LOCATION", Escape(e.Location))
+	}
+	if e.Description != "" {
+		cw.line("DESCRIPTION", Escape(e.Description))
+	}
+	if len(e.Categories) > 0 {
+		cats := make([]string, len(e.Categories))
+		for i, c := range e.Categories {
+			cats[i] = Escape(c)
+		}
+		cw.line("CATEGORIES", strings.Join(cats, ","))
+	}
+	if e.RRule != "" {
+		cw.line("RRULE", e.RRule)
+	}
+	for _, p := range e.Props {
+		if strings.HasPrefix(p.Name, "X-") {
+			cw.line(p.Name, p.Value)
+		}
+	}
+	cw.line("END", "VEVENT")
+}
+
+// Close writes END:VCALENDAR and flushes.
+func (cw *Writer) Close() error {
+	cw.line("END", "VCALENDAR")
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.w.Flush()
+}
+
+// line writes NAME:VALUE folded at 75 octets without splitting a rune.
+func (cw *Writer) line(name, value string) {
+	if cw.err != nil {
+		return
+	}
+	s := name + ":" + value
+	for len(s) > 75 {
+		cut := 75
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		_, cw.err = cw.w.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+	}
+	if cw.err == nil {
+		_, cw.err = cw.w.WriteString(s + "\r\n")
+	}
+}
+
+// Escape applies TEXT escaping.
+func Escape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
@@ -0,0 +1,335 @@
+// Package layout computes the geometry of a classic timetable grid (days
+// across, periods down) independently of the output format. The PDF and
+// image exports draw the boxes it returns; they only differ in how text
+// is measured and painted.
+package layout
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Period is one teaching period, in minutes since midnight.
+type Period struct {
+	Label string
+	Start int
+	End   int
+}
+
+// DefaultPeriods are the UCAS teaching periods.
+var DefaultPeriods = []Period{
+	{"1", 8*60 + 0, 8*60 + 45},
+	{"2", 8*60 + 50, 9*60 + 35},
+	{"3", 9*60 + 45, 10*60 + 30},
+	{"4", 10*60 + 35, 11*60 + 20},
+	{"5", 11*60 + 25, 12*60 + 10},
+	{"6", 13*60 + 30, 14*60 + 15},
+	{"7", 14*60 + 20, 15*60 + 5},
+	{"8", 15*60 + 15, 16*60 + 0},
+	{"9", 16*60 + 5, 16*60 + 50},
+	{"10", 16*60 + 55, 17*60 + 40},
+	{"11", 18*60 + 10, 18*60 + 55},
+	{"12", 19*60 + 0, 19*60 + 45},
+	{"13", 19*60 + 50, 20*60 + 35},
+}
+
+// ParsePeriods reads "08:00-08:45,08:50-09:35,..."; periods are numbered
+// from 1 in the order given.
+func ParsePeriods(s string) ([]Period, error) {
+	var out []Period
+	for i, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, fmt.Errorf("period %q: want HH:MM-HH:MM", part)
+		}
+		start, err := clockMinutes(from)
+		if err != nil {
+			return nil, err
+		}
+		end, err := clockMinutes(to)
+		if err != nil {
+			return nil, err
+		}
+		if end <= start {
+			return nil, fmt.Errorf("period %q ends before it starts", part)
+		}
+		out = append(out, Period{Label: fmt.Sprint(i + 1), Start: start, End: end})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no periods")
+	}
+	return out, nil
+}
+
+func clockMinutes(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Clock formats minutes since midnight as HH:MM.
+func Clock(m int) string {
+	return fmt.Sprintf("%02d:%02d", m/60, m%60)
+}
+
+// Entry is one timed item of the week.
+type Entry struct {
+	Day     int // 0 (Mon) .. 6 (Sun)
+	Start   int // minutes since midnight
+	End     int
+	Title   string
+	Room    string
+	Teacher string
+	// Key picks the colour; entries with the same key share it.
+	Key string
+	// Muted entries (personal events, imported calendars) are drawn fainter.
+	Muted bool
+}
+
+// Day is one column header.
+type Day struct {
+	Label string // 周一
+	Date  string // 09-08
+	Note  string // holiday label, all-day entries
+}
+
+// Week is the input of the layout.
+type Week struct {
+	Title    string
+	Subtitle string
+	Days     [7]Day
+	Entries  []Entry
+}
+
+// Options sets the page geometry, in the unit of the renderer (points for
+// PDF, pixels for images).
+type Options struct {
+	Width, Height   float64
+	Margin          float64
+	TitleHeight     float64 // title and subtitle band
+	DayHeaderHeight float64
+	PeriodWidth     float64 // left column with period numbers and times
+	FootnoteHeight  float64 // reserved for entries outside every period
+	Periods         []Period
+	// Weekend columns are dropped when they hold nothing, unless KeepWeekend.
+	KeepWeekend bool
+	// Days, when set, lists the columns to show (0 = Monday) instead;
+	// entries of other days are left out.
+	Days    []int
+	Palette []color.RGBA
+}
+
+// Box is a rectangle with its origin at the top left.
+type Box struct{ X, Y, W, H float64 }
+
+// Column is a laid out day header.
+type Column struct {
+	Box
+	Day
+	Index int // 0 (Mon) .. 6 (Sun)
+}
+
+// Row is a laid out period.
+type Row struct {
+	Box
+	Period
+}
+
+// Block is a laid out entry.
+type Block struct {
+	Box
+	Entry
+	Fill color.RGBA
+}
+
+// Grid is the result of Layout.
+type Grid struct {
+	Width, Height float64
+	Title         Box
+	Columns       []Column
+	Rows          []Row
+	// Body is the cell area below the day headers and right of the period column.
+	Body      Box
+	Blocks    []Block
+	Footnotes []string
+	Footer    Box
+}
+
+// Layout places w on a page described by opt.
+func Layout(w Week, opt Options) Grid {
+	periods := opt.Periods
+	if len(periods) == 0 {
+		periods = DefaultPeriods
+	}
+	palette := opt.Palette
+	if len(palette) == 0 {
+		palette = DefaultPalette
+	}
+
+	days := opt.Days
+	if len(days) == 0 {
+		days = []int{0, 1, 2, 3, 4}
+		for d := 5; d < 7; d++ {
+			if opt.KeepWeekend || w.Days[d].Note != "" || hasEntries(w.Entries, d) {
+				days = append(days, d)
+			}
+		}
+		// Sunday without Saturday would look odd.
+		if len(days) == 6 && days[5] == 6 {
+			days = []int{0, 1, 2, 3, 4, 5, 6}
+		}
+	}
+
+	g := Grid{Width: opt.Width, Height: opt.Height}
+	inner := Box{X: opt.Margin, Y: opt.Margin, W: opt.Width - 2*opt.Margin, H: opt.Height - 2*opt.Margin}
+	g.Title = Box{X: inner.X, Y: inner.Y, W: inner.W, H: opt.TitleHeight}
+	headerY := inner.Y + opt.TitleHeight
+	g.Footer = Box{X: inner.X, Y: inner.Y + inner.H - opt.FootnoteHeight, W: inner.W, H: opt.FootnoteHeight}
+	g.Body = Box{
+		X: inner.X + opt.PeriodWidth,
+		Y: headerY + opt.DayHeaderHeight,
+		W: inner.W - opt.PeriodWidth,
+		H: g.Footer.Y - headerY - opt.DayHeaderHeight,
+	}
+
+	colW := g.Body.W / float64(len(days))
+	col := map[int]float64{}
+	for i, d := range days {
+		x := g.Body.X + float64(i)*colW
+		col[d] = x
+		g.Columns = append(g.Columns, Column{Box: Box{X: x, Y: headerY, W: colW, H: opt.DayHeaderHeight}, Day: w.Days[d], Index: d})
+	}
+	rowH := g.Body.H / float64(len(periods))
+	for i, p := range periods {
+		g.Rows = append(g.Rows, Row{Box: Box{X: inner.X, Y: g.Body.Y + float64(i)*rowH, W: opt.PeriodWidth, H: rowH}, Period: p})
+	}
+
+	entries := append([]Entry(nil), w.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Day != entries[j].Day {
+			return entries[i].Day < entries[j].Day
+		}
+		return entries[i].Start < entries[j].Start
+	})
+	type placed struct {
+		e          Entry
+		first, end int // row span [first, end)
+		lane       int
+	}
+	var byDay [7][]*placed
+	for _, e := range entries {
+		if _, shown := col[e.Day]; !shown {
+			continue
+		}
+		first, end := -1, -1
+		for i, p := range periods {
+			if e.Start < p.End && e.End > p.Start {
+				if first < 0 {
+					first = i
+				}
+				end = i + 1
+			}
+		}
+		if first < 0 {
+			g.Footnotes = append(g.Footnotes, footnote(w.Days, e))
+			continue
+		}
+		byDay[e.Day] = append(byDay[e.Day], &placed{e: e, first: first, end: end})
+	}
+
+	for d, ps := range byDay {
+		// Overlapping entries share the column side by side: each gets the
+		// first free lane, and a cluster of mutually overlapping entries is
+		// split into as many lanes as it needs.
+		for len(ps) > 0 {
+			clusterEnd := ps[0].end
+			n := 1
+			for n < len(ps) && ps[n].first < clusterEnd {
+				clusterEnd = max(clusterEnd, ps[n].end)
+				n++
+			}
+			cluster := ps[:n]
+			var laneEnd []int
+			for _, p := range cluster {
+				p.lane = -1
+				for l, end := range laneEnd {
+					if end <= p.first {
+						p.lane, laneEnd[l] = l, p.end
+						break
+					}
+				}
+				if p.lane < 0 {
+					p.lane = len(laneEnd)
+					laneEnd = append(laneEnd, p.end)
+				}
+			}
+			laneW := colW / float64(len(laneEnd))
+			for _, p := range cluster {
+				g.Blocks = append(g.Blocks, Block{
+					Box: Box{
+						X: col[d] + float64(p.lane)*laneW,
+						Y: g.Body.Y + float64(p.first)*rowH,
+						W: laneW,
+						H: float64(p.end-p.first) * rowH,
+					},
+					Entry: p.e,
+					Fill:  ColorFor(p.e.Key, palette),
+				})
+			}
+			ps = ps[n:]
+		}
+	}
+	return g
+}
+
+func hasEntries(entries []Entry, day int) bool {
+	for _, e := range entries {
+		if e.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+func footnote(days [7]Day, e Entry) string {
+	s := fmt.Sprintf("%s %s-%s %s", days[e.Day%7].Label, Clock(e.Start), Clock(e.End), e.Title)
+	if e.Room != "" {
+		s += " @ " + e.Room
+	}
+	return s
+}
+
+// DefaultPalette is a set of light fills that keep black text readable.
+var DefaultPalette = []color.RGBA{
+	{0xFF, 0xD6, 0xD6, 0xFF}, {0xFF, 0xE4, 0xC2, 0xFF}, {0xFF, 0xF3, 0xB8, 0xFF},
+	{0xDD, 0xF2, 0xC4, 0xFF}, {0xC8, 0xEE, 0xDC, 0xFF}, {0xC4, 0xEA, 0xF2, 0xFF},
+	{0xCF, 0xDF, 0xFA, 0xFF}, {0xDD, 0xD6, 0xF7, 0xFF}, {0xF2, 0xD4, 0xF0, 0xFF},
+	{0xE6, 0xDD, 0xD0, 0xFF},
+}
+
+// ColorFor picks the palette colour of key; the same key always gets the
+// same colour.
+func ColorFor(key string, palette []color.RGBA) color.RGBA {
+	if len(palette) == 0 {
+		palette = DefaultPalette
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return palette[h.Sum32()%uint32(len(palette))]
+}
+
+// Blend mixes t of b into a.
+func Blend(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(x, y uint8) uint8 { return uint8(float64(x)*(1-t) + float64(y)*t + 0.5) }
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 0xFF}
+}
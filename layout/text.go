@@ -0,0 +1,99 @@
+package layout
+
+import (
+	"strings"
+
+	"LoginTest/textwidth"
+)
+
+// Measure returns the width of s at the renderer's current font size.
+type Measure func(s string) float64
+
+// Wrap breaks s into lines no wider than width. CJK text may break
+// between any two characters; runs of other characters are kept together
+// when they fit on a line.
+func Wrap(s string, width float64, measure Measure) []string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	var lines []string
+	var line []rune
+	for _, tok := range tokens(s) {
+		if len(line) == 0 {
+			tok = strings.TrimLeft(tok, " ")
+		}
+		if measure(string(line)+tok) <= width {
+			line = append(line, []rune(tok)...)
+			continue
+		}
+		if len(line) > 0 {
+			lines = append(lines, strings.TrimSpace(string(line)))
+			line = nil
+		}
+		tok = strings.TrimLeft(tok, " ")
+		// A token wider than the line is broken by character.
+		for _, r := range tok {
+			if len(line) > 0 && measure(string(line)+string(r)) > width {
+				lines = append(lines, string(line))
+				line = nil
+			}
+			line = append(line, r)
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, strings.TrimSpace(string(line)))
+	}
+	return lines
+}
+
+// tokens splits s into single wide characters and runs of other characters
+// (each run carrying the spaces before it).
+func tokens(s string) []string {
+	var out []string
+	var run []rune
+	flush := func() {
+		if len(run) > 0 {
+			out = append(out, string(run))
+			run = nil
+		}
+	}
+	for _, r := range s {
+		switch {
+		case textwidth.IsWide(r):
+			flush()
+			out = append(out, string(r))
+		case r == ' ' && len(run) > 0 && run[len(run)-1] != ' ':
+			flush()
+			run = append(run, r)
+		default:
+			run = append(run, r)
+		}
+	}
+	flush()
+	return out
+}
+
+// Fit wraps each paragraph into width and keeps at most maxLines lines,
+// ending the last kept line with "…" when something was cut.
+func Fit(paragraphs []string, width float64, maxLines int, measure Measure) []string {
+	var lines []string
+	cut := false
+	for _, p := range paragraphs {
+		for _, l := range Wrap(p, width, measure) {
+			if len(lines) == maxLines {
+				cut = true
+				break
+			}
+			lines = append(lines, l)
+		}
+	}
+	if cut && len(lines) > 0 {
+		last := []rune(lines[len(lines)-1])
+		for len(last) > 0 && measure(string(last)+"…") > width {
+			last = last[:len(last)-1]
+		}
+		lines[len(lines)-1] = string(last) + "…"
+	}
+	return lines
+}
@@ -0,0 +1,71 @@
+package layout
+
+import (
+	"strings"
+	"testing"
+
+	"LoginTest/textwidth"
+)
+
+// cells measures like a terminal: wide characters take two cells.
+func cells(s string) float64 {
+	n := 0.0
+	for _, r := range s {
+		if textwidth.IsWide(r) {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		width float64
+		want  []string
+	}{
+		{"empty", "   ", 10, nil},
+		{"fits", "Linear Algebra", 20, []string{"Linear Algebra"}},
+		{"breaks at spaces", "Linear Algebra II", 10, []string{"Linear", "Algebra II"}},
+		{"CJK breaks anywhere", "高等数学甲", 6, []string{"高等数", "学甲"}},
+		{"mixed keeps Latin runs", "数学 A-101 教室", 6, []string{"数学", "A-101", "教室"}},
+		{"long word is split by character", "Mathematics", 4, []string{"Math", "emat", "ics"}},
+		{"wide punctuation counts as wide", "“数学”", 4, []string{"“数", "学”"}},
+	}
+	for _, tt := range tests {
+		got := Wrap(tt.in, tt.width, cells)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("%s: Wrap(%q, %v) = %q, want %q", tt.name, tt.in, tt.width, got, tt.want)
+		}
+		for _, l := range got {
+			if cells(l) > tt.width {
+				t.Errorf("%s: line %q is wider than %v", tt.name, l, tt.width)
+			}
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name       string
+		paragraphs []string
+		width      float64
+		maxLines   int
+		want       []string
+	}{
+		{"everything fits", []string{"数学", "A-101"}, 6, 3, []string{"数学", "A-101"}},
+		{"paragraphs start new lines", []string{"ab", "cd"}, 10, 2, []string{"ab", "cd"}},
+		{"cut adds an ellipsis", []string{"高等数学甲"}, 6, 1, []string{"高等…"}},
+		{"cut across paragraphs", []string{"数学", "教一楼", "王老师"}, 6, 2, []string{"数学", "教一…"}},
+		{"skips empty paragraphs", []string{"", "数学"}, 6, 1, []string{"数学"}},
+	}
+	for _, tt := range tests {
+		got := Fit(tt.paragraphs, tt.width, tt.maxLines, cells)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: Fit(%q, %v, %d) = %q, want %q", tt.name, tt.paragraphs, tt.width, tt.maxLines, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Package lenient decodes upstream JSON into the typed structs of auth and
+// models without failing on type drift. Scalars are coerced between string,
+// number, bool and null, object keys the struct does not know are kept in
+// an Extra map, and every coercion is reported so callers can count them.
+package lenient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Coercion records one value that did not match the target type.
+type Coercion struct {
+	Path string // e.g. "result[].classroomLatitude"
+	From string // json kind found: string, number, bool, null, array, object
+	To   string // Go kind expected
+}
+
+// Report summarises a lenient decode.
+type Report struct {
+	Coercions []Coercion
+	Unknown   []string // paths of object keys without a matching field
+}
+
+// Drifted reports whether anything had to be coerced or was unknown.
+func (r Report) Drifted() bool { return len(r.Coercions) > 0 || len(r.Unknown) > 0 }
+
+// extraField is the struct field that collects unknown keys. It must be a
+// map[string]any and should be tagged `json:"-"`.
+const extraField = "Extra"
+
+// Decode unmarshals data into v, which must be a non-nil pointer. It only
+// fails when data is not JSON at all or v cannot hold a JSON object/array.
+func Decode(data []byte, v any) (Report, error) {
+	var rep Report
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return rep, fmt.Errorf("lenient: Decode needs a non-nil pointer, got %T", v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw any
+	if err := dec.Decode(&raw); err != nil {
+		return rep, err
+	}
+	d := decoder{rep: &rep}
+	if err := d.assign(rv.Elem(), raw, ""); err != nil {
+		return rep, err
+	}
+	return rep, nil
+}
+
+type decoder struct {
+	rep *Report
+}
+
+func (d *decoder) coerce(path string, raw any, to reflect.Kind) {
+	d.rep.Coercions = append(d.rep.Coercions, Coercion{Path: path, From: jsonKind(raw), To: to.String()})
+}
+
+func jsonKind(raw any) string {
+	switch raw.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "bool"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", raw)
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// assign stores raw into dst. Only the top level may return an error.
+func (d *decoder) assign(dst reflect.Value, raw any, path string) error {
+	// Types with their own UnmarshalJSON (e.g. time.Time) decide themselves.
+	if dst.CanAddr() && dst.Addr().Type().Implements(unmarshalerType) {
+		b, _ := json.Marshal(raw)
+		err := dst.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(b)
+		if _, isString := raw.(string); err != nil || (dst.Kind() == reflect.String && !isString) {
+			d.coerce(path, raw, dst.Kind())
+		}
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		switch x := raw.(type) {
+		case string:
+			dst.SetString(x)
+		case json.Number:
+			dst.SetString(x.String())
+			d.coerce(path, raw, reflect.String)
+		case bool:
+			dst.SetString(strconv.FormatBool(x))
+			d.coerce(path, raw, reflect.String)
+		case nil:
+			dst.SetString("")
+			d.coerce(path, raw, reflect.String)
+		default:
+			b, _ := json.Marshal(x)
+			dst.SetString(string(b))
+			d.coerce(path, raw, reflect.String)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toFloat(raw)
+		if _, isNum := raw.(json.Number); !isNum {
+			d.coerce(path, raw, dst.Kind())
+		}
+		if ok {
+			dst.SetInt(int64(n))
+		}
+
+	case reflect.Float32, reflect.Float64:
+		n, ok := toFloat(raw)
+		if _, isNum := raw.(json.Number); !isNum {
+			d.coerce(path, raw, dst.Kind())
+		}
+		if ok {
+			dst.SetFloat(n)
+		}
+
+	case reflect.Bool:
+		switch x := raw.(type) {
+		case bool:
+			dst.SetBool(x)
+		default:
+			n, _ := toFloat(raw)
+			s, _ := raw.(string)
+			dst.SetBool(n != 0 || strings.EqualFold(s, "true"))
+			d.coerce(path, raw, reflect.Bool)
+		}
+
+	case reflect.Interface:
+		if raw != nil {
+			dst.Set(reflect.ValueOf(raw))
+		}
+
+	case reflect.Pointer:
+		if raw == nil {
+			return nil
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return d.assign(dst.Elem(), raw, path)
+
+	case reflect.Slice:
+		switch x := raw.(type) {
+		case []any:
+			s := reflect.MakeSlice(dst.Type(), len(x), len(x))
+			for i, item := range x {
+				d.assign(s.Index(i), item, path+"[]")
+			}
+			dst.Set(s)
+		case nil:
+			dst.Set(reflect.Zero(dst.Type()))
+		case string:
+			// Upstream sends "" for an empty list now and then.
+			dst.Set(reflect.Zero(dst.Type()))
+			if x != "" {
+				d.coerce(path, raw, reflect.Slice)
+			}
+		default:
+			// A single element where a list was expected.
+			s := reflect.MakeSlice(dst.Type(), 1, 1)
+			d.assign(s.Index(0), raw, path+"[]")
+			dst.Set(s)
+			d.coerce(path, raw, reflect.Slice)
+		}
+
+	case reflect.Map:
+		obj, ok := raw.(map[string]any)
+		if !ok {
+			if raw != nil {
+				d.coerce(path, raw, reflect.Map)
+			}
+			return nil
+		}
+		if dst.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		m := reflect.MakeMapWithSize(dst.Type(), len(obj))
+		for k, item := range obj {
+			v := reflect.New(dst.Type().Elem()).Elem()
+			d.assign(v, item, join(path, k))
+			m.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), v)
+		}
+		dst.Set(m)
+
+	case reflect.Struct:
+		obj, ok := raw.(map[string]any)
+		if !ok {
+			if path == "" {
+				return fmt.Errorf("lenient: expected a JSON object, got %s", jsonKind(raw))
+			}
+			if raw != nil {
+				d.coerce(path, raw, reflect.Struct)
+			}
+			return nil
+		}
+		d.assignStruct(dst, obj, path)
+
+	default:
+		d.coerce(path, raw, dst.Kind())
+	}
+	return nil
+}
+
+func (d *decoder) assignStruct(dst reflect.Value, obj map[string]any, path string) {
+	t := dst.Type()
+	known := map[string]bool{}
+	var extra reflect.Value
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Name == extraField && f.Type == reflect.TypeOf(map[string]any(nil)) {
+			extra = dst.Field(i)
+			continue
+		}
+		name, skip := fieldName(f)
+		if skip {
+			continue
+		}
+		known[name] = true
+		raw, ok := lookup(obj, name)
+		if !ok {
+			continue
+		}
+		d.assign(dst.Field(i), raw, join(path, name))
+	}
+	for k, v := range obj {
+		if known[k] || knownFold(known, k) {
+			continue
+		}
+		d.rep.Unknown = append(d.rep.Unknown, join(path, k))
+		if extra.IsValid() {
+			if extra.IsNil() {
+				extra.Set(reflect.ValueOf(map[string]any{}))
+			}
+			extra.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
+		}
+	}
+}
+
+// fieldName mirrors encoding/json's tag handling.
+func fieldName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, false
+}
+
+// lookup finds key like encoding/json: exact match first, then case-insensitive.
+func lookup(obj map[string]any, key string) (any, bool) {
+	if v, ok := obj[key]; ok {
+		return v, true
+	}
+	for k, v := range obj {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func knownFold(known map[string]bool, key string) bool {
+	for k := range known {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(raw any) (float64, bool) {
+	switch x := raw.(type) {
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,150 @@
+package lenient
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type item struct {
+	Name string `json:"name"`
+}
+
+type record struct {
+	S     string         `json:"s"`
+	I     int            `json:"i"`
+	F     float64        `json:"f"`
+	B     bool           `json:"b"`
+	L     []string       `json:"l"`
+	Items []item         `json:"items"`
+	P     *int           `json:"p"`
+	Extra map[string]any `json:"-"`
+}
+
+func intPtr(n int) *int { return &n }
+
+func TestDecodeCoercions(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		want      record
+		coercions []Coercion
+		unknown   []string
+	}{
+		{
+			name: "matching types",
+			json: `{"s":"x","i":3,"f":1.5,"b":true,"l":["a"],"p":7}`,
+			want: record{S: "x", I: 3, F: 1.5, B: true, L: []string{"a"}, P: intPtr(7)},
+		},
+		{
+			name:      "number into string",
+			json:      `{"s":42}`,
+			want:      record{S: "42"},
+			coercions: []Coercion{{"s", "number", "string"}},
+		},
+		{
+			name:      "null into string",
+			json:      `{"s":null}`,
+			coercions: []Coercion{{"s", "null", "string"}},
+		},
+		{
+			name:      "object into string keeps the JSON",
+			json:      `{"s":{"a":1}}`,
+			want:      record{S: `{"a":1}`},
+			coercions: []Coercion{{"s", "object", "string"}},
+		},
+		{
+			name:      "numeric string into int and float",
+			json:      `{"i":"12","f":"2.5"}`,
+			want:      record{I: 12, F: 2.5},
+			coercions: []Coercion{{"f", "string", "float64"}, {"i", "string", "int"}},
+		},
+		{
+			name:      "string into bool",
+			json:      `{"b":"TRUE"}`,
+			want:      record{B: true},
+			coercions: []Coercion{{"b", "string", "bool"}},
+		},
+		{
+			name:      "zero into bool",
+			json:      `{"b":0}`,
+			coercions: []Coercion{{"b", "number", "bool"}},
+		},
+		{
+			name:      "single object where a list was expected",
+			json:      `{"items":{"name":"n"}}`,
+			want:      record{Items: []item{{Name: "n"}}},
+			coercions: []Coercion{{"items", "object", "slice"}},
+		},
+		{
+			name:      "non-empty string where a list was expected",
+			json:      `{"l":"a"}`,
+			coercions: []Coercion{{"l", "string", "slice"}},
+		},
+		{
+			name: "empty string as an empty list",
+			json: `{"l":""}`,
+		},
+		{
+			name:      "element paths inside lists",
+			json:      `{"items":[{"name":1}]}`,
+			want:      record{Items: []item{{Name: "1"}}},
+			coercions: []Coercion{{"items[].name", "number", "string"}},
+		},
+		{
+			name: "null pointer stays nil",
+			json: `{"p":null}`,
+		},
+		{
+			name:    "unknown keys go to Extra",
+			json:    `{"s":"x","extraKey":"v"}`,
+			want:    record{S: "x", Extra: map[string]any{"extraKey": "v"}},
+			unknown: []string{"extraKey"},
+		},
+		{
+			name: "keys match case-insensitively",
+			json: `{"S":"x"}`,
+			want: record{S: "x"},
+		},
+	}
+	for _, tt := range tests {
+		var got record
+		rep, err := Decode([]byte(tt.json), &got)
+		if err != nil {
+			t.Errorf("%s: Decode: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+		sort.Slice(rep.Coercions, func(i, j int) bool { return rep.Coercions[i].Path < rep.Coercions[j].Path })
+		if len(rep.Coercions) != len(tt.coercions) || (len(tt.coercions) > 0 && !reflect.DeepEqual(rep.Coercions, tt.coercions)) {
+			t.Errorf("%s: coercions = %v, want %v", tt.name, rep.Coercions, tt.coercions)
+		}
+		if len(rep.Unknown) != len(tt.unknown) || (len(tt.unknown) > 0 && !reflect.DeepEqual(rep.Unknown, tt.unknown)) {
+			t.Errorf("%s: unknown = %v, want %v", tt.name, rep.Unknown, tt.unknown)
+		}
+		if rep.Drifted() != (len(tt.coercions)+len(tt.unknown) > 0) {
+			t.Errorf("%s: Drifted = %v", tt.name, rep.Drifted())
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var r record
+	tests := []struct {
+		name string
+		json string
+		v    any
+	}{
+		{"not JSON", `<html>`, &r},
+		{"array at the top", `[1]`, &r},
+		{"non-pointer target", `{}`, r},
+		{"nil pointer", `{}`, (*record)(nil)},
+	}
+	for _, tt := range tests {
+		if _, err := Decode([]byte(tt.json), tt.v); err == nil {
+			t.Errorf("%s: Decode succeeded, want an error", tt.name)
+		}
+	}
+}
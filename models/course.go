@@ -5,6 +5,8 @@ type TodayCoursesResponse struct {
 	STATUS string         `json:"STATUS"`
 	Total  string         `json:"total"`
 	Result []CourseRecord `json:"result"`
+	// Extra 保存宽松解码时遇到的未知字段
+	Extra map[string]any `json:"-"`
 }
 
 // CourseRecord 表示一条课程日程
@@ -43,4 +45,6 @@ type CourseRecord struct {
 	AssistantStuName   string `json:"assistantStuName"`
 	CourseSchedType    string `json:"courseSchedType"`
 	ClassEndTime       string `json:"classEndTime"`
+	// Extra 保存宽松解码时遇到的未知字段
+	Extra map[string]any `json:"-"`
 }
@@ -0,0 +1,279 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UpstreamLocation 是解析上游时间字段所用的时区：上游返回的均为北京时间，
+// 与服务端配置的 ACADEMIC_TZ 无关，不应修改
+var UpstreamLocation = loadShanghai()
+
+func loadShanghai() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Shanghai"); err == nil {
+		return loc
+	}
+	// 容器中可能缺少 tzdata，北京时间没有夏令时，固定偏移即可
+	return time.FixedZone("CST", 8*3600)
+}
+
+// SignStatus 表示签到状态
+type SignStatus int
+
+const (
+	SignStatusUnknown SignStatus = iota
+	SignStatusUnsigned
+	SignStatusSigned
+)
+
+func (s SignStatus) String() string {
+	switch s {
+	case SignStatusUnsigned:
+		return "unsigned"
+	case SignStatusSigned:
+		return "signed"
+	}
+	return "unknown"
+}
+
+// MarshalText 以字符串形式输出枚举
+func (s SignStatus) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// ParseSignStatus 解析上游 signStatus（"1" 已签到，"0" 或空为未签到）
+func ParseSignStatus(raw string) (SignStatus, error) {
+	switch strings.TrimSpace(raw) {
+	case "1":
+		return SignStatusSigned, nil
+	case "0", "":
+		return SignStatusUnsigned, nil
+	}
+	return SignStatusUnknown, fmt.Errorf("unknown sign status %q", raw)
+}
+
+// SchedType 表示排课类型（courseSchedType）
+type SchedType int
+
+const (
+	SchedTypeUnknown SchedType = iota
+	SchedTypeRegular
+	SchedTypeTemporary
+)
+
+func (t SchedType) String() string {
+	switch t {
+	case SchedTypeRegular:
+		return "regular"
+	case SchedTypeTemporary:
+		return "temporary"
+	}
+	return "unknown"
+}
+
+// MarshalText 以字符串形式输出枚举
+func (t SchedType) MarshalText() ([]byte, error) { return []byte(t.String()), nil }
+
+// ParseSchedType 解析上游 courseSchedType（目前观察到 "1" 常规排课、"2" 临时调课）
+func ParseSchedType(raw string) (SchedType, error) {
+	switch strings.TrimSpace(raw) {
+	case "1", "":
+		return SchedTypeRegular, nil
+	case "2":
+		return SchedTypeTemporary, nil
+	}
+	return SchedTypeUnknown, fmt.Errorf("unknown course sched type %q", raw)
+}
+
+// WeekDay 表示星期几。JSON 中与上游及 /courses/range 的 weekDay 一致，
+// 为 1（周一）到 7（周日），而不是 time.Weekday 的 0（周日）到 6
+type WeekDay time.Weekday
+
+// Weekday 转换为 time.Weekday
+func (d WeekDay) Weekday() time.Weekday { return time.Weekday(d) }
+
+// MarshalJSON 输出 1-7，周日为 7
+func (d WeekDay) MarshalJSON() ([]byte, error) {
+	n := int(d)
+	if n == 0 {
+		n = 7
+	}
+	return strconv.AppendInt(nil, int64(n), 10), nil
+}
+
+// Course 是 CourseRecord 的规范化领域类型：时间为 UpstreamLocation 下的
+// time.Time，坐标为 float64，签到状态与排课类型为枚举。
+type Course struct {
+	ID             string     `json:"id"`
+	UUID           string     `json:"uuid"`
+	CourseID       string     `json:"courseId"`
+	CourseName     string     `json:"courseName"`
+	CourseType     string     `json:"courseType"`
+	CourseNum      string     `json:"courseNum"`
+	SemesterID     string     `json:"semesterId"`
+	SemesterName   string     `json:"semesterName"`
+	TeacherID      string     `json:"teacherId"`
+	TeacherName    string     `json:"teacherName"`
+	ClassroomID    string     `json:"classroomId"`
+	ClassroomName  string     `json:"classroomName"`
+	TeachBuildID   string     `json:"teachBuildId"`
+	TeachBuildName string     `json:"teachBuildName"`
+	StoreyID       string     `json:"storeyId"`
+	StoreyName     string     `json:"storeyName"`
+	WeekDay        WeekDay    `json:"weekDay"`
+	TeachDate      time.Time  `json:"teachDate"`
+	Begin          time.Time  `json:"begin"`
+	End            time.Time  `json:"end"`
+	Longitude      *float64   `json:"longitude,omitempty"`
+	Latitude       *float64   `json:"latitude,omitempty"`
+	SignStatus     SignStatus `json:"signStatus"`
+	SchedType      SchedType  `json:"schedType"`
+}
+
+// FieldError 描述一个无法解析的上游字段
+type FieldError struct {
+	Index int    `json:"index"` // 在课程列表中的下标，单条转换时为 0
+	Field string `json:"field"`
+	Value string `json:"value"`
+	Err   string `json:"error"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("course %d: %s=%q: %s", e.Index, e.Field, e.Value, e.Err)
+}
+
+// ParseCourseTime 解析上游时间戳，如 "2024-03-01 08:30:00" 或毫秒时间戳
+func ParseCourseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, UpstreamLocation); err == nil {
+			return t, nil
+		}
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil && ms > 0 {
+		return time.UnixMilli(ms).In(UpstreamLocation), nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognised time %q", s)
+}
+
+// parseTeachDate 解析 teachTime，可能是日期或完整时间
+func parseTeachDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if t, err := time.ParseInLocation(layout, s, UpstreamLocation); err == nil {
+			return t, nil
+		}
+	}
+	t, err := ParseCourseTime(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, UpstreamLocation), nil
+}
+
+// parseWeekDay 解析 weekDay（1-7 表示周一至周日，0 也视为周日）
+func parseWeekDay(s string) (WeekDay, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 || n > 7 {
+		return 0, fmt.Errorf("weekday out of range")
+	}
+	return WeekDay(n % 7), nil
+}
+
+// parseCoordinate 解析经纬度，空字符串或 0 视为缺失
+func parseCoordinate(s string, limit float64) (*float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	if v < -limit || v > limit {
+		return nil, fmt.Errorf("out of range")
+	}
+	if v == 0 {
+		return nil, nil
+	}
+	return &v, nil
+}
+
+// NormalizeCourse 将 CourseRecord 转换为 Course，并报告所有无法解析的字段。
+// 出错字段保持零值，其余字段照常填充。
+func NormalizeCourse(r CourseRecord) (Course, []FieldError) {
+	c := Course{
+		ID:             r.ID,
+		UUID:           r.UUID,
+		CourseID:       r.CourseID,
+		CourseName:     r.CourseName,
+		CourseType:     r.CourseType,
+		CourseNum:      r.CourseNum,
+		SemesterID:     r.SemesterID,
+		SemesterName:   r.SemesterName,
+		TeacherID:      r.TeacherID,
+		TeacherName:    r.TeacherName,
+		ClassroomID:    r.ClassroomID,
+		ClassroomName:  r.ClassroomName,
+		TeachBuildID:   r.TeachBuildID,
+		TeachBuildName: r.TeachBuildName,
+		StoreyID:       r.StoreyID,
+		StoreyName:     r.StoreyName,
+	}
+	var errs []FieldError
+	fail := func(field, value string, err error) {
+		errs = append(errs, FieldError{Field: field, Value: value, Err: err.Error()})
+	}
+
+	var err error
+	if c.Begin, err = ParseCourseTime(r.ClassBeginTime); err != nil {
+		fail("classBeginTime", r.ClassBeginTime, err)
+	}
+	if c.End, err = ParseCourseTime(r.ClassEndTime); err != nil {
+		fail("classEndTime", r.ClassEndTime, err)
+	}
+	if r.TeachTime != "" {
+		if c.TeachDate, err = parseTeachDate(r.TeachTime); err != nil {
+			fail("teachTime", r.TeachTime, err)
+		}
+	} else if !c.Begin.IsZero() {
+		y, m, d := c.Begin.Date()
+		c.TeachDate = time.Date(y, m, d, 0, 0, 0, 0, UpstreamLocation)
+	}
+	if r.WeekDay != "" {
+		if c.WeekDay, err = parseWeekDay(r.WeekDay); err != nil {
+			fail("weekDay", r.WeekDay, err)
+		}
+	} else if !c.TeachDate.IsZero() {
+		c.WeekDay = WeekDay(c.TeachDate.Weekday())
+	}
+	if c.Longitude, err = parseCoordinate(r.ClassroomLongitude, 180); err != nil {
+		fail("classroomLongitude", r.ClassroomLongitude, err)
+	}
+	if c.Latitude, err = parseCoordinate(r.ClassroomLatitude, 90); err != nil {
+		fail("classroomLatitude", r.ClassroomLatitude, err)
+	}
+	if c.SignStatus, err = ParseSignStatus(r.SignStatus); err != nil {
+		fail("signStatus", r.SignStatus, err)
+	}
+	if c.SchedType, err = ParseSchedType(r.CourseSchedType); err != nil {
+		fail("courseSchedType", r.CourseSchedType, err)
+	}
+	return c, errs
+}
+
+// NormalizeCourses 批量转换，FieldError.Index 指向出错的课程
+func NormalizeCourses(records []CourseRecord) ([]Course, []FieldError) {
+	out := make([]Course, 0, len(records))
+	var errs []FieldError
+	for i, r := range records {
+		c, fe := NormalizeCourse(r)
+		for _, e := range fe {
+			e.Index = i
+			errs = append(errs, e)
+		}
+		out = append(out, c)
+	}
+	return out, errs
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"LoginTest/models"
+)
+
+// ------------------------------
+// Upcoming-class webhook reminders
+// ------------------------------
+// Every user may register any number of webhooks. A background scheduler
+// walks the cached timetable of each user and POSTs a reminder to the
+// webhook LeadMinutes before ClassBeginTime. The payload is either JSON or
+// plain text and can be customised with a text/template.
+
+const (
+	notificationsFile  = "notifications.json"
+	defaultLeadMinutes = 10
+	maxLeadMinutes     = 24 * 60
+	// Default tick of the reminder scheduler, override with REMINDER_INTERVAL.
+	defaultReminderInterval = 30 * time.Second
+)
+
+// Webhook is one reminder target owned by a user.
+type Webhook struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	LeadMinutes int       `json:"leadMinutes"`
+	Format      string    `json:"format"` // "json" or "text"
+	Template    string    `json:"template,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// reminderPayload is what gets sent (or fed to the template) for one class.
+type reminderPayload struct {
+	Event          string              `json:"event"`
+	CourseID       string              `json:"courseId"`
+	CourseName     string              `json:"courseName"`
+	TeacherName    string              `json:"teacherName"`
+	ClassroomName  string              `json:"classroomName"`
+	TeachBuildName string              `json:"teachBuildName"`
+	ClassBeginTime string              `json:"classBeginTime"`
+	ClassEndTime   string              `json:"classEndTime"`
+	MinutesBefore  int                 `json:"minutesBefore"`
+	DayLabel       string              `json:"dayLabel,omitempty"` // holiday / 调休 label of the class day
+	Course         models.CourseRecord `json:"-"`
+}
+
+var (
+	// uid -> webhooks
+	webhooks   = map[string][]*Webhook{}
+	webhooksMu sync.RWMutex
+
+	// reminders already delivered, keyed by webhook/course/begin time
+	sentReminders   = map[string]time.Time{}
+	sentRemindersMu sync.Mutex
+
+	// Webhooks only reach public addresses (see outbound.go) unless
+	// WEBHOOK_ALLOWED_NETS opens others, e.g. 127.0.0.0/8 for a local
+	// test receiver.
+	webhookClient      = publicOnlyClient(10*time.Second, &webhookAllowedNets)
+	webhookAllowedNets []*net.IPNet
+)
+
+// loadWebhooks reads WEBHOOK_ALLOWED_NETS and restores registered webhooks
+// from state/notifications.json.
+func loadWebhooks() {
+	webhookAllowedNets = loadAllowedNets("WEBHOOK_ALLOWED_NETS")
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+	if err := loadDataFile(notificationsFile, &webhooks); err != nil {
+		log.Printf("load webhooks failed: %v", err)
+	}
+	if webhooks == nil {
+		webhooks = map[string][]*Webhook{}
+	}
+}
+
+// saveWebhooksLocked persists webhooks; caller must hold webhooksMu.
+func saveWebhooksLocked() {
+	if err := saveDataFile(notificationsFile, webhooks); err != nil {
+		log.Printf("save webhooks failed: %v", err)
+	}
+}
+
+// handleNotifications manages the webhooks of the session user.
+// GET    -> { webhooks: [...] }
+// POST   JSON { url, leadMinutes, format: "json"|"text", template } -> 201 { webhook }
+// DELETE ?id=... -> 204
+func handleNotifications(w http.ResponseWriter, r *http.Request) {
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+
+	switch r.Method {
+	case http.MethodGet:
+		webhooksMu.RLock()
+		list := append([]*Webhook{}, webhooks[sess.UID]...)
+		webhooksMu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"webhooks": list,
+		})
+
+	case http.MethodPost:
+		var body Webhook
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+			return
+		}
+		hook, err := newWebhook(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		webhooksMu.Lock()
+		webhooks[sess.UID] = append(webhooks[sess.UID], hook)
+		saveWebhooksLocked()
+		webhooksMu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"webhook": hook,
+		})
+
+	case http.MethodDelete:
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if id == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		webhooksMu.Lock()
+		list := webhooks[sess.UID]
+		found := false
+		for i, h := range list {
+			if h.ID == id {
+				webhooks[sess.UID] = append(list[:i:i], list[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if found {
+			saveWebhooksLocked()
+		}
+		webhooksMu.Unlock()
+		if !found {
+			http.Error(w, "webhook not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// newWebhook validates client input and fills defaults.
+func newWebhook(in Webhook) (*Webhook, error) {
+	u, err := url.Parse(strings.TrimSpace(in.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url must be an absolute http(s) url")
+	}
+	lead := in.LeadMinutes
+	if lead == 0 {
+		lead = defaultLeadMinutes
+	}
+	if lead < 0 || lead > maxLeadMinutes {
+		return nil, fmt.Errorf("leadMinutes must be between 1 and %d", maxLeadMinutes)
+	}
+	format := strings.ToLower(strings.TrimSpace(in.Format))
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "text" {
+		return nil, fmt.Errorf("format must be json or text")
+	}
+	if in.Template != "" {
+		if _, err := template.New("reminder").Parse(in.Template); err != nil {
+			return nil, fmt.Errorf("invalid template: %v", err)
+		}
+	}
+	id, err := genToken()
+	if err != nil {
+		return nil, fmt.Errorf("create webhook id failed")
+	}
+	return &Webhook{
+		ID:          id[:12],
+		URL:         u.String(),
+		LeadMinutes: lead,
+		Format:      format,
+		Template:    in.Template,
+		CreatedAt:   clock(),
+	}, nil
+}
+
+// startReminderScheduler runs the reminder loop in the background.
+func startReminderScheduler() {
+	interval := defaultReminderInterval
+	if v := os.Getenv("REMINDER_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		} else {
+			log.Printf("ignoring invalid REMINDER_INTERVAL %q", v)
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runReminders(clock())
+		}
+	}()
+}
+
+// runReminders delivers every reminder that became due at now.
+func runReminders(now time.Time) {
+	now = now.In(academicLoc)
+	webhooksMu.RLock()
+	owners := make(map[string][]Webhook, len(webhooks))
+	for uid, list := range webhooks {
+		for _, h := range list {
+			owners[uid] = append(owners[uid], *h)
+		}
+	}
+	webhooksMu.RUnlock()
+
+	for uid, hooks := range owners {
+		// A long lead time can reach into tomorrow's first class.
+		var courses []models.CourseRecord
+		for _, day := range []time.Time{now, now.AddDate(0, 0, 1)} {
+			if snap, ok := cachedCourses(uid, day.Format("20060102")); ok {
+				courses = append(courses, snap.Courses.Result...)
+			}
+		}
+		for _, c := range courses {
+			begin, err := models.ParseCourseTime(c.ClassBeginTime)
+			if err != nil || !now.Before(begin) {
+				continue
+			}
+			for _, h := range hooks {
+				if now.Before(begin.Add(-time.Duration(h.LeadMinutes) * time.Minute)) {
+					continue
+				}
+				key := h.ID + "|" + c.ID + "|" + c.ClassBeginTime
+				if !markReminderSent(key, begin) {
+					continue
+				}
+				payload := reminderPayload{
+					Event:          "class.upcoming",
+					CourseID:       c.CourseID,
+					CourseName:     c.CourseName,
+					TeacherName:    c.TeacherName,
+					ClassroomName:  c.ClassroomName,
+					TeachBuildName: c.TeachBuildName,
+					ClassBeginTime: c.ClassBeginTime,
+					ClassEndTime:   c.ClassEndTime,
+					MinutesBefore:  int(begin.Sub(now).Round(time.Minute) / time.Minute),
+					Course:         c,
+				}
+				if d, ok := calendarDay(begin.Format("20060102")); ok {
+					payload.DayLabel = d.Label()
+				}
+				go deliverWebhook(h, payload)
+			}
+		}
+	}
+	pruneSentReminders(now)
+}
+
+// markReminderSent returns false if key was already delivered.
+func markReminderSent(key string, begin time.Time) bool {
+	sentRemindersMu.Lock()
+	defer sentRemindersMu.Unlock()
+	if _, done := sentReminders[key]; done {
+		return false
+	}
+	sentReminders[key] = begin
+	return true
+}
+
+// pruneSentReminders forgets reminders of classes that started a day ago.
+func pruneSentReminders(now time.Time) {
+	sentRemindersMu.Lock()
+	for k, begin := range sentReminders {
+		if now.Sub(begin) > 24*time.Hour {
+			delete(sentReminders, k)
+		}
+	}
+	sentRemindersMu.Unlock()
+}
+
+// deliverWebhook renders the payload for h and POSTs it.
+func deliverWebhook(h Webhook, payload reminderPayload) {
+	body, contentType, err := renderReminder(h, payload)
+	if err != nil {
+		log.Printf("render reminder for webhook %s failed: %v", h.ID, err)
+		return
+	}
+	postWebhook(h, body, contentType)
+}
+
+// postWebhook POSTs an already rendered body to h.
+func postWebhook(h Webhook, body []byte, contentType string) {
+	resp, err := webhookClient.Post(h.URL, contentType, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("deliver to webhook %s failed: %v", h.ID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("webhook %s answered %s", h.ID, resp.Status)
+	}
+}
+
+// renderReminder builds the request body of a reminder.
+func renderReminder(h Webhook, payload reminderPayload) ([]byte, string, error) {
+	contentType := "application/json"
+	if h.Format == "text" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	if h.Template == "" {
+		if h.Format == "text" {
+			text := fmt.Sprintf("%s 将于 %d 分钟后开始（%s %s，%s）",
+				payload.CourseName, payload.MinutesBefore, payload.TeachBuildName, payload.ClassroomName, payload.ClassBeginTime)
+			if payload.DayLabel != "" {
+				text = "[" + payload.DayLabel + "] " + text
+			}
+			return []byte(text), contentType, nil
+		}
+		b, err := json.Marshal(payload)
+		return b, contentType, err
+	}
+	tmpl, err := template.New("reminder").Parse(h.Template)
+	if err != nil {
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, payload); err != nil {
+		return nil, "", err
+	}
+	if h.Format == "json" && !json.Valid(buf.Bytes()) {
+		return nil, "", fmt.Errorf("template output is not valid json")
+	}
+	return buf.Bytes(), contentType, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunRemindersSelection(t *testing.T) {
+	setClock(t, lateUTC)
+	delivered := make(chan string, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p reminderPayload
+		_ = json.NewDecoder(r.Body).Decode(&p)
+		delivered <- r.URL.Path + " " + p.CourseName
+	}))
+	defer srv.Close()
+	oldNets := webhookAllowedNets
+	webhookAllowedNets = []*net.IPNet{{IP: net.IPv4(127, 0, 0, 0), Mask: net.CIDRMask(8, 32)}}
+	t.Cleanup(func() { webhookAllowedNets = oldNets })
+
+	const uid = "u1"
+	webhooks[uid] = []*Webhook{
+		{ID: "a", URL: srv.URL + "/a", LeadMinutes: 30, Format: "json"},
+		{ID: "b", URL: srv.URL + "/b", LeadMinutes: 120, Format: "json"},
+		{ID: "c", URL: srv.URL + "/c", LeadMinutes: maxLeadMinutes, Format: "json"},
+	}
+	// Beijing times; "now" is 2026-03-02 07:30, so the longest lead time
+	// reaches tomorrow's 07:00 class but not the one at 08:00.
+	cacheDay(uid, "20260301", course("0", "Sunday", "2026-03-01 19:00:00", "2026-03-01 20:30:00"))
+	cacheDay(uid, "20260302",
+		course("1", "Eight", "2026-03-02 08:00:00", "2026-03-02 08:45:00"),
+		course("2", "Nine", "2026-03-02 09:00:00", "2026-03-02 09:45:00"),
+		course("3", "Early", "2026-03-02 07:00:00", "2026-03-02 07:45:00"))
+	cacheDay(uid, "20260303",
+		course("4", "Tomorrow", "2026-03-03 07:00:00", "2026-03-03 07:45:00"),
+		course("5", "TooFar", "2026-03-03 08:00:00", "2026-03-03 08:45:00"))
+
+	want := []string{
+		"/a Eight",
+		"/b Eight", "/b Nine",
+		"/c Eight", "/c Nine", "/c Tomorrow",
+	}
+	runReminders(clock())
+	runReminders(clock()) // nothing is sent twice
+	sentRemindersMu.Lock()
+	sent := len(sentReminders)
+	sentRemindersMu.Unlock()
+	if sent != len(want) {
+		t.Errorf("%d reminders marked sent, want %d", sent, len(want))
+	}
+
+	var got []string
+	deadline := time.After(2 * time.Second)
+	for len(got) < len(want) {
+		select {
+		case d := <-delivered:
+			got = append(got, d)
+		case <-deadline:
+			t.Fatalf("delivered only %q before the deadline, want %q", got, want)
+		}
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("delivered %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"LoginTest/models"
+)
+
+// ------------------------------
+// Now / next
+// ------------------------------
+// /courses/now answers "what am I in and what is next" for status-bar
+// widgets and watch shortcuts. It works on the merged schedule of today
+// (and, after the last entry, tomorrow) in the academic time zone. Days
+// already in the cache are used as they are; only a missing today is
+// fetched from upstream.
+
+// nowEntry is the current or next entry with its countdown.
+type nowEntry struct {
+	scheduleItem
+	// MinutesRemaining is set for the current entry, MinutesUntil for the next.
+	MinutesRemaining *int `json:"minutesRemaining,omitempty"`
+	MinutesUntil     *int `json:"minutesUntil,omitempty"`
+}
+
+// handleCoursesNow returns the current and the next entry of the session user.
+// GET /courses/now -> { now, dateStr, current, next, transition, summary }
+func handleCoursesNow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+
+	now := academicNow()
+	todayStr := now.Format("20060102")
+	var courses []models.CourseRecord
+	if snap, ok := cachedCourses(sess.UID, todayStr); ok {
+		courses = snap.Courses.Result
+	} else {
+		var err error
+		if courses, err = coursesForDay(sess, todayStr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	items := daySchedule(sess.UID, todayStr, courses)
+	tomorrowStr := now.AddDate(0, 0, 1).Format("20060102")
+	var tomorrow []models.CourseRecord
+	if snap, ok := cachedCourses(sess.UID, tomorrowStr); ok {
+		tomorrow = snap.Courses.Result
+	}
+	items = append(items, daySchedule(sess.UID, tomorrowStr, tomorrow)...)
+
+	current, next, prev := nowAndNext(items, now)
+	payload := map[string]any{
+		"now":     now.Format(time.RFC3339),
+		"dateStr": todayStr,
+		"current": current,
+		"next":    next,
+		"summary": nowSummary(current, next),
+	}
+	// The walk to the next entry, from the current one or the one just over.
+	if next != nil {
+		from := current
+		if from == nil && prev != nil {
+			from = &nowEntry{scheduleItem: *prev}
+		}
+		if from != nil {
+			if ts := transitions([]scheduleItem{from.scheduleItem, next.scheduleItem}); len(ts) > 0 {
+				payload["transition"] = ts[0]
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+// nowAndNext picks the timed entry running at now (the one ending first if
+// several overlap), the first one starting after now, and the last one
+// that already ended.
+func nowAndNext(items []scheduleItem, now time.Time) (current, next *nowEntry, prev *scheduleItem) {
+	for i := range items {
+		it := items[i]
+		if it.AllDay {
+			continue
+		}
+		switch {
+		case !now.Before(it.Begin) && now.Before(it.End):
+			if current == nil || it.End.Before(current.End) {
+				left := minutesCeil(it.End.Sub(now))
+				current = &nowEntry{scheduleItem: it, MinutesRemaining: &left}
+			}
+		case it.Begin.After(now):
+			if next == nil || it.Begin.Before(next.Begin) {
+				until := minutesCeil(it.Begin.Sub(now))
+				next = &nowEntry{scheduleItem: it, MinutesUntil: &until}
+			}
+		case !it.End.After(now):
+			if prev == nil || it.End.After(prev.End) {
+				prev = &items[i]
+			}
+		}
+	}
+	return current, next, prev
+}
+
+func minutesCeil(d time.Duration) int {
+	return int(math.Ceil(d.Minutes()))
+}
+
+// nowSummary is a one-line description for status bars, e.g.
+// "高等数学 @ 教一楼 101，还剩 23 分钟 | 下一节 大学英语 10:00 @ 教二楼 201（35 分钟后）".
+func nowSummary(current, next *nowEntry) string {
+	var parts []string
+	if current != nil {
+		s := current.Title
+		if current.Location != "" {
+			s += " @ " + current.Location
+		}
+		parts = append(parts, fmt.Sprintf("%s，还剩 %d 分钟", s, *current.MinutesRemaining))
+	}
+	if next != nil {
+		s := "下一节 " + next.Title + " " + next.Begin.In(academicLoc).Format("15:04")
+		if next.Location != "" {
+			s += " @ " + next.Location
+		}
+		parts = append(parts, fmt.Sprintf("%s（%s后）", s, formatCountdown(*next.MinutesUntil)))
+	}
+	if len(parts) == 0 {
+		return "今天没有更多课程"
+	}
+	return strings.Join(parts, " | ")
+}
+
+// formatCountdown renders minutes as "35 分钟" or "2 小时 5 分钟".
+func formatCountdown(minutes int) string {
+	if minutes < 60 {
+		return fmt.Sprintf("%d 分钟", minutes)
+	}
+	if minutes%60 == 0 {
+		return fmt.Sprintf("%d 小时", minutes/60)
+	}
+	return fmt.Sprintf("%d 小时 %d 分钟", minutes/60, minutes%60)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ------------------------------
+// Requests to user-supplied URLs
+// ------------------------------
+// Calendar feeds are fetched from, and webhooks posted to, URLs that users
+// choose. Their clients only connect to public addresses: loopback,
+// private, link-local and similar targets are refused at dial time, which
+// also covers redirects and DNS answers that change after validation.
+// FEED_ALLOWED_NETS and WEBHOOK_ALLOWED_NETS (comma separated CIDRs) let
+// an operator open specific internal networks.
+
+var errNonPublicAddress = errors.New("url points to a non-public address")
+
+// loadAllowedNets parses the CIDR list in the environment variable env.
+func loadAllowedNets(env string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, v := range strings.Split(os.Getenv(env), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			log.Printf("ignoring invalid %s entry %q", env, v)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// publicOnlyClient is an HTTP client that refuses non-public addresses
+// other than those in *allowed, which is read on every dial.
+func publicOnlyClient(timeout time.Duration, allowed *[]*net.IPNet) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: publicOnlyDial(allowed)}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// publicOnlyDial is a net.Dialer Control that checks the resolved address
+// of every connection.
+func publicOnlyDial(allowed *[]*net.IPNet) func(network, address string, _ syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return errNonPublicAddress
+		}
+		for _, n := range *allowed {
+			if n.Contains(ip) {
+				return nil
+			}
+		}
+		if !publicIP(ip) {
+			return errNonPublicAddress
+		}
+		return nil
+	}
+}
+
+// sharedAddressSpace is 100.64.0.0/10 (RFC 6598, carrier-grade NAT).
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP reports whether ip is a globally routed unicast address.
+func publicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
@@ -0,0 +1,166 @@
+// Package pdf writes simple vector PDF documents: filled and stroked
+// rectangles, lines and text. Text uses the Adobe CJK font STSong-Light,
+// which PDF readers supply themselves, so nothing has to be embedded and
+// Chinese prints without shipping a font file. Coordinates are in points
+// with the origin at the top left of the page, like package layout.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"LoginTest/textwidth"
+)
+
+// A4 page size in points.
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Document is a PDF being assembled.
+type Document struct {
+	Title string
+	pages []*Page
+}
+
+// Page is one page; drawing calls append to its content stream.
+type Page struct {
+	Width, Height float64
+	buf           bytes.Buffer
+}
+
+// New returns an empty document.
+func New(title string) *Document {
+	return &Document{Title: title}
+}
+
+// AddPage appends a page of the given size.
+func (d *Document) AddPage(width, height float64) *Page {
+	p := &Page{Width: width, Height: height}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+func (p *Page) y(y float64) float64 { return p.Height - y }
+
+func rgb(c color.RGBA) string {
+	return fmt.Sprintf("%.3f %.3f %.3f", float64(c.R)/255, float64(c.G)/255, float64(c.B)/255)
+}
+
+// FillRect paints the rectangle at x, y (top left).
+func (p *Page) FillRect(x, y, w, h float64, c color.RGBA) {
+	fmt.Fprintf(&p.buf, "%s rg %.2f %.2f %.2f %.2f re f\n", rgb(c), x, p.y(y+h), w, h)
+}
+
+// StrokeRect outlines the rectangle at x, y (top left).
+func (p *Page) StrokeRect(x, y, w, h, width float64, c color.RGBA) {
+	fmt.Fprintf(&p.buf, "%s RG %.2f w %.2f %.2f %.2f %.2f re S\n", rgb(c), width, x, p.y(y+h), w, h)
+}
+
+// Line strokes a straight line.
+func (p *Page) Line(x1, y1, x2, y2, width float64, c color.RGBA) {
+	fmt.Fprintf(&p.buf, "%s RG %.2f w %.2f %.2f m %.2f %.2f l S\n", rgb(c), width, x1, p.y(y1), x2, p.y(y2))
+}
+
+// Text draws s with its baseline at y.
+func (p *Page) Text(x, y, size float64, c color.RGBA, s string) {
+	fmt.Fprintf(&p.buf, "BT %s rg /F1 %.2f Tf %.2f %.2f Td <%s> Tj ET\n", rgb(c), size, x, p.y(y), ucs2(s))
+}
+
+// TextWidth is the advance width of s at size: one em for wide characters
+// (textwidth.IsWide), half an em for the rest (see the /W array below).
+func TextWidth(s string, size float64) float64 {
+	w := 0.0
+	for _, r := range s {
+		if textwidth.IsWide(r) {
+			w += 1
+		} else {
+			w += 0.5
+		}
+	}
+	return w * size
+}
+
+// ucs2 encodes s for the UniGB-UCS2-H CMap. Characters outside the Basic
+// Multilingual Plane have no code there and become "?".
+func ucs2(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 0xFFFF {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+// textString encodes s as a UTF-16BE text string for the document info.
+func textString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// WriteTo writes the finished document.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string) int {
+		offsets = append(offsets, out.Len())
+		n := len(offsets)
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", n, body)
+		return n
+	}
+	out.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+
+	// Object numbers: 1 catalog, 2 pages, 3 info, 4-6 font, then per page
+	// the page and its content stream.
+	const firstPage = 7
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj(fmt.Sprintf("<< /Title %s /Producer (LoginTest) >>", textString(d.Title)))
+	obj("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [5 0 R] >>")
+	// CIDs 1-95 of Adobe-GB1 are the proportional Latin glyphs; treating
+	// them as half width matches TextWidth closely enough for layout.
+	obj("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> " +
+		"/FontDescriptor 6 0 R /DW 1000 /W [1 95 500] >>")
+	obj("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+		"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+
+	for i, p := range d.pages {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		if _, err := zw.Write(p.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		if err := zw.Close(); err != nil {
+			return 0, err
+		}
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 4 0 R >> >> /Contents %d 0 R >>", p.Width, p.Height, firstPage+2*i+1))
+		obj(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", z.Len(), z.Bytes()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.WriteTo(w)
+}
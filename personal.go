@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"LoginTest/ical"
+	"LoginTest/models"
+)
+
+// ------------------------------
+// Personal events
+// ------------------------------
+// Students keep their own entries (seminars, lab meetings, exams) next to
+// the upstream timetable. An entry may repeat with an RFC 5545 RRULE; the
+// stored series is expanded on demand into the merged schedule.
+
+const (
+	personalEventsFile = "personal_events.json"
+	maxPersonalEvents  = 500
+)
+
+// PersonalEvent is one entry (or recurring series) owned by a user.
+type PersonalEvent struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Category string    `json:"category,omitempty"` // free text, e.g. seminar / lab / exam
+	Location string    `json:"location,omitempty"`
+	Notes    string    `json:"notes,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	AllDay   bool      `json:"allDay,omitempty"`
+	RRule    string    `json:"rrule,omitempty"`
+	// ExDates are the days (YYYY-MM-DD) on which a recurring entry is skipped.
+	ExDates   []string  `json:"exdates,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// personalEventInput is the request body of POST and PUT. Times accept
+// RFC 3339, "2006-01-02 15:04" (academic time zone) or, for all-day
+// entries, "2006-01-02".
+type personalEventInput struct {
+	Title    string   `json:"title"`
+	Category string   `json:"category"`
+	Location string   `json:"location"`
+	Notes    string   `json:"notes"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	AllDay   bool     `json:"allDay"`
+	RRule    string   `json:"rrule"`
+	ExDates  []string `json:"exdates"`
+}
+
+var (
+	// uid -> events
+	personalEvents   = map[string][]*PersonalEvent{}
+	personalEventsMu sync.RWMutex
+)
+
+// loadPersonalEvents restores the store from state/personal_events.json.
+func loadPersonalEvents() {
+	personalEventsMu.Lock()
+	defer personalEventsMu.Unlock()
+	if err := loadDataFile(personalEventsFile, &personalEvents); err != nil {
+		log.Printf("load personal events failed: %v", err)
+	}
+	if personalEvents == nil {
+		personalEvents = map[string][]*PersonalEvent{}
+	}
+}
+
+// savePersonalEventsLocked persists the store; caller must hold personalEventsMu.
+func savePersonalEventsLocked() {
+	if err := saveDataFile(personalEventsFile, personalEvents); err != nil {
+		log.Printf("save personal events failed: %v", err)
+	}
+}
+
+// handlePersonalEvents manages the personal events of the session user.
+// GET    -> { events: [...] }; with ?from=&to= (date expressions) -> { from, to, occurrences: [...] }
+// POST   JSON personalEventInput -> 201 { event }
+// PUT    ?id=... JSON personalEventInput -> { event }
+// DELETE ?id=... -> 204
+func handlePersonalEvents(w http.ResponseWriter, r *http.Request) {
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		if q.Get("from") == "" && q.Get("to") == "" {
+			personalEventsMu.RLock()
+			list := append([]*PersonalEvent{}, personalEvents[sess.UID]...)
+			personalEventsMu.RUnlock()
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"events": list,
+			})
+			return
+		}
+		from, err := resolveDate(q.Get("from"), clock())
+		if err != nil {
+			http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		to := from.AddDate(0, 0, 6)
+		if v := strings.TrimSpace(q.Get("to")); v != "" {
+			if to, err = resolveDate(v, clock()); err != nil {
+				http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if to.Before(from) {
+			http.Error(w, "to is before from", http.StatusBadRequest)
+			return
+		}
+		occurrences := personalItems(sess.UID, from, to.AddDate(0, 0, 1))
+		if occurrences == nil {
+			occurrences = []scheduleItem{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"from":        from.Format("20060102"),
+			"to":          to.Format("20060102"),
+			"occurrences": occurrences,
+		})
+
+	case http.MethodPost:
+		var body personalEventInput
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+			return
+		}
+		ev, err := newPersonalEvent(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id, err := genToken()
+		if err != nil {
+			http.Error(w, "create event id failed", http.StatusInternalServerError)
+			return
+		}
+		ev.ID = id[:12]
+		ev.CreatedAt = clock()
+		ev.UpdatedAt = ev.CreatedAt
+		personalEventsMu.Lock()
+		if len(personalEvents[sess.UID]) >= maxPersonalEvents {
+			personalEventsMu.Unlock()
+			http.Error(w, fmt.Sprintf("at most %d personal events", maxPersonalEvents), http.StatusConflict)
+			return
+		}
+		personalEvents[sess.UID] = append(personalEvents[sess.UID], ev)
+		savePersonalEventsLocked()
+		personalEventsMu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"event": ev,
+		})
+
+	case http.MethodPut:
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if id == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		var body personalEventInput
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+			return
+		}
+		ev, err := newPersonalEvent(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		personalEventsMu.Lock()
+		var updated *PersonalEvent
+		for i, old := range personalEvents[sess.UID] {
+			if old.ID == id {
+				ev.ID, ev.CreatedAt, ev.UpdatedAt = old.ID, old.CreatedAt, clock()
+				personalEvents[sess.UID][i] = ev
+				updated = ev
+				break
+			}
+		}
+		if updated != nil {
+			savePersonalEventsLocked()
+		}
+		personalEventsMu.Unlock()
+		if updated == nil {
+			http.Error(w, "event not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"event": updated,
+		})
+
+	case http.MethodDelete:
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if id == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		personalEventsMu.Lock()
+		list := personalEvents[sess.UID]
+		found := false
+		for i, ev := range list {
+			if ev.ID == id {
+				personalEvents[sess.UID] = append(list[:i:i], list[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if found {
+			savePersonalEventsLocked()
+		}
+		personalEventsMu.Unlock()
+		if !found {
+			http.Error(w, "event not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// newPersonalEvent validates client input and fills defaults: a timed
+// entry without end lasts one hour, an all-day entry one day.
+func newPersonalEvent(in personalEventInput) (*PersonalEvent, error) {
+	ev := &PersonalEvent{
+		Title:    strings.TrimSpace(in.Title),
+		Category: strings.TrimSpace(in.Category),
+		Location: strings.TrimSpace(in.Location),
+		Notes:    in.Notes,
+		AllDay:   in.AllDay,
+		RRule:    strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(in.RRule, "RRULE:"))),
+	}
+	if ev.Title == "" {
+		return nil, fmt.Errorf("title is required")
+	}
+	var err error
+	if ev.Start, err = parseEventTime(in.Start, ev.AllDay); err != nil {
+		return nil, fmt.Errorf("invalid start: %v", err)
+	}
+	switch {
+	case strings.TrimSpace(in.End) != "":
+		if ev.End, err = parseEventTime(in.End, ev.AllDay); err != nil {
+			return nil, fmt.Errorf("invalid end: %v", err)
+		}
+		if ev.AllDay {
+			// An all-day end date is inclusive for clients, exclusive inside.
+			ev.End = ev.End.AddDate(0, 0, 1)
+		}
+	case ev.AllDay:
+		ev.End = ev.Start.AddDate(0, 0, 1)
+	default:
+		ev.End = ev.Start.Add(time.Hour)
+	}
+	if !ev.End.After(ev.Start) {
+		return nil, fmt.Errorf("end must be after start")
+	}
+	if ev.RRule != "" {
+		rule, err := ical.ParseRRule(ev.RRule, academicLoc)
+		if err != nil {
+			return nil, err
+		}
+		if !rule.Occurs(ev.Start) {
+			return nil, fmt.Errorf("rrule never produces an occurrence")
+		}
+	}
+	for _, d := range in.ExDates {
+		t, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(d), academicLoc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exdate %q", d)
+		}
+		ev.ExDates = append(ev.ExDates, t.Format("2006-01-02"))
+	}
+	return ev, nil
+}
+
+// parseEventTime accepts RFC 3339, upstream-style local times and, for
+// all-day entries, plain dates (other date expressions work too).
+func parseEventTime(s string, allDay bool) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("missing")
+	}
+	if allDay {
+		return resolveDate(s, clock())
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(academicLoc), nil
+	}
+	return models.ParseCourseTime(s)
+}
+
+// icalEvent converts the entry for expansion and export.
+func (ev PersonalEvent) icalEvent() ical.Event {
+	e := ical.Event{
+		UID:         ev.ID,
+		Summary:     ev.Title,
+		Description: ev.Notes,
+		Location:    ev.Location,
+		Start:       ev.Start.In(academicLoc),
+		End:         ev.End.In(academicLoc),
+		AllDay:      ev.AllDay,
+		RRule:       ev.RRule,
+	}
+	if ev.Category != "" {
+		e.Categories = []string{ev.Category}
+	}
+	h, m, s := e.Start.Clock()
+	for _, d := range ev.ExDates {
+		if day, err := time.ParseInLocation("2006-01-02", d, academicLoc); err == nil {
+			e.ExDates = append(e.ExDates, time.Date(day.Year(), day.Month(), day.Day(), h, m, s, 0, academicLoc))
+		}
+	}
+	return e
+}
+
+// personalItems expands the entries of uid that overlap [from, to).
+func personalItems(uid string, from, to time.Time) []scheduleItem {
+	personalEventsMu.RLock()
+	list := make([]PersonalEvent, 0, len(personalEvents[uid]))
+	for _, ev := range personalEvents[uid] {
+		list = append(list, *ev)
+	}
+	personalEventsMu.RUnlock()
+
+	var items []scheduleItem
+	for _, ev := range list {
+		e := ev.icalEvent()
+		starts, err := e.Occurrences(from, to, academicLoc)
+		if err != nil {
+			log.Printf("expand personal event %s failed: %v", ev.ID, err)
+			continue
+		}
+		for _, start := range starts {
+			item := scheduleItem{
+				Source:    sourcePersonal,
+				ID:        ev.ID,
+				Title:     ev.Title,
+				Location:  ev.Location,
+				Category:  ev.Category,
+				Notes:     ev.Notes,
+				Begin:     start,
+				End:       start.Add(e.End.Sub(e.Start)),
+				AllDay:    ev.AllDay,
+				Recurring: ev.RRule != "",
+			}
+			if item.Recurring {
+				item.ID += "@" + start.Format("20060102T1504")
+			}
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Begin.Before(items[j].Begin) })
+	return items
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"LoginTest/models"
+	"LoginTest/render"
+)
+
+// ------------------------------
+// Range and week views
+// ------------------------------
+// The upstream API only answers one day at a time. These views stitch days
+// together, reusing a cached day when it was fetched recently.
+
+const (
+	// A cached day younger than this is served without asking upstream.
+	rangeCacheTTL = 5 * time.Minute
+	maxRangeDays  = 31
+)
+
+// dayView is one day of a range response.
+type dayView struct {
+	DateStr      string                `json:"dateStr"`
+	Date         string                `json:"date"`
+	WeekDay      int                   `json:"weekDay"` // 1 (Mon) .. 7 (Sun), like upstream
+	TeachingWeek int                   `json:"teachingWeek"`
+	Semester     *weekInfo             `json:"semester,omitempty"`
+	Calendar     *calendarView         `json:"calendar,omitempty"`
+	Result       []models.CourseRecord `json:"result"`
+	Schedule     []scheduleItem        `json:"schedule"`
+	Transitions  []transition          `json:"transitions,omitempty"`
+}
+
+// coursesForDay returns the timetable of dateStr, from cache when fresh.
+// Only a successful reply (HTTP 200, STATUS "0") counts: an error reply or
+// an undecodable body also comes back without courses, and must not be
+// shown as a day without classes.
+func coursesForDay(sess *Session, dateStr string) ([]models.CourseRecord, error) {
+	if snap, ok := cachedCourses(sess.UID, dateStr); ok && clock().Sub(snap.FetchedAt) < rangeCacheTTL {
+		return snap.Courses.Result, nil
+	}
+	_, status, today, _, err := fetchCourses(sess, dateStr)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("upstream answered %d for %s", status, dateStr)
+	}
+	if today.STATUS != "0" {
+		return nil, fmt.Errorf("upstream answered STATUS %q for %s", today.STATUS, dateStr)
+	}
+	return today.Result, nil
+}
+
+// buildDays collects the views of every day from..to inclusive.
+func buildDays(sess *Session, from, to time.Time) ([]dayView, error) {
+	var days []dayView
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		dateStr := d.Format("20060102")
+		courses, err := coursesForDay(sess, dateStr)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, newDayView(sess.UID, d, courses))
+	}
+	return days, nil
+}
+
+// newDayView annotates the courses of one day for uid.
+func newDayView(uid string, d time.Time, courses []models.CourseRecord) dayView {
+	dateStr := d.Format("20060102")
+	if courses == nil {
+		courses = []models.CourseRecord{}
+	}
+	view := dayView{
+		DateStr:  dateStr,
+		Date:     d.Format("2006-01-02"),
+		WeekDay:  daysFromMonday(d.Weekday()) + 1,
+		Result:   courses,
+		Schedule: daySchedule(uid, dateStr, courses),
+	}
+	view.Transitions = transitions(view.Schedule)
+	if info, ok := teachingWeekInfo(dateStr); ok {
+		view.TeachingWeek = info.TeachingWeek
+		view.Semester = &info
+	}
+	if d, ok := calendarDay(dateStr); ok {
+		view.Calendar = &calendarView{Day: *d, Label: d.Label()}
+	}
+	return view
+}
+
+// handleCoursesRange returns the courses of several days.
+// GET /courses/range?from=<date expr>&to=<date expr> (at most 31 days)
+func handleCoursesRange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+
+	q := r.URL.Query()
+	from, err := resolveDate(q.Get("from"), clock())
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to := from.AddDate(0, 0, 6)
+	if v := strings.TrimSpace(q.Get("to")); v != "" {
+		if to, err = resolveDate(v, clock()); err != nil {
+			http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "to is before from", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) >= maxRangeDays*24*time.Hour {
+		http.Error(w, fmt.Sprintf("range is limited to %d days", maxRangeDays), http.StatusBadRequest)
+		return
+	}
+
+	days, err := buildDays(sess, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	f := render.Negotiate(r)
+	if notModified(w, r, daysETag(days, f, from.Format("20060102"), to.Format("20060102"))) {
+		return
+	}
+	if f != render.JSON {
+		title := from.Format("2006-01-02") + " ~ " + to.Format("2006-01-02")
+		writeDaysTable(w, f, http.StatusOK, title, days)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"from": from.Format("20060102"),
+		"to":   to.Format("20060102"),
+		"days": days,
+	})
+}
+
+// handleCoursesWeek returns Monday..Sunday of one week.
+// GET /courses/week?week=7 (teaching week) or ?date=<date expr> (week containing it, default today)
+func handleCoursesWeek(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+
+	monday, err := weekMonday(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days, err := buildDays(sess, monday, monday.AddDate(0, 0, 6))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	f := render.Negotiate(r)
+	if notModified(w, r, daysETag(days, f, monday.Format("20060102"))) {
+		return
+	}
+	if f != render.JSON {
+		title := monday.Format("2006-01-02") + " ~ " + monday.AddDate(0, 0, 6).Format("2006-01-02")
+		if n := days[0].TeachingWeek; n > 0 {
+			title = fmt.Sprintf("第 %d 周（%s）", n, title)
+		}
+		writeDaysTable(w, f, http.StatusOK, title, days)
+		return
+	}
+	payload := map[string]any{
+		"from": monday.Format("20060102"),
+		"to":   monday.AddDate(0, 0, 6).Format("20060102"),
+		"days": days,
+	}
+	annotateWeek(payload, monday.Format("20060102"))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+// weekMonday resolves ?week= or ?date= to the Monday of that week.
+func weekMonday(r *http.Request) (time.Time, error) {
+	q := r.URL.Query()
+	if v := strings.TrimSpace(q.Get("week")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid week %q", v)
+		}
+		return resolveDate(fmt.Sprintf("week:%d:mon", n), clock())
+	}
+	day, err := resolveDate(q.Get("date"), clock())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %v", err)
+	}
+	return day.AddDate(0, 0, -daysFromMonday(day.Weekday())), nil
+}
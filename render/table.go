@@ -0,0 +1,245 @@
+// Package render writes tabular course data in the non-JSON formats the
+// API offers: CSV for spreadsheets, aligned plain text for terminals and
+// Markdown tables for chat apps.
+package render
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"LoginTest/textwidth"
+)
+
+// Format is an output format name as used in ?format=.
+type Format string
+
+const (
+	JSON     Format = "json"
+	CSV      Format = "csv"
+	Text     Format = "text"
+	Markdown Format = "markdown"
+)
+
+var aliases = map[string]Format{
+	"json": JSON, "csv": CSV, "text": Text, "txt": Text, "plain": Text,
+	"markdown": Markdown, "md": Markdown,
+}
+
+var mediaTypes = map[string]Format{
+	"application/json": JSON, "text/csv": CSV, "text/plain": Text, "text/markdown": Markdown,
+}
+
+// Negotiate picks the format from ?format= or, failing that, the first
+// media type in Accept that is supported. The default is JSON, so
+// browsers' "text/html,...,*/*" keeps getting JSON.
+func Negotiate(r *http.Request) Format {
+	if f, ok := aliases[strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))]; ok {
+		return f
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		if f, ok := mediaTypes[mt]; ok {
+			return f
+		}
+		if mt == "text/html" || mt == "*/*" {
+			break
+		}
+	}
+	return JSON
+}
+
+// ContentType is the Content-Type header value for f.
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case Text:
+		return "text/plain; charset=utf-8"
+	case Markdown:
+		return "text/markdown; charset=utf-8"
+	}
+	return "application/json"
+}
+
+// Column is one table column. Key is the CSV header, Label the heading of
+// the text and Markdown tables.
+type Column struct {
+	Key   string
+	Label string
+}
+
+// Table is what the renderers write. Title and Notes are printed above
+// the text and Markdown tables and left out of CSV.
+type Table struct {
+	Title   string
+	Notes   []string
+	Columns []Column
+	Rows    [][]string
+}
+
+// Write renders t in format f; JSON is not handled here.
+func Write(w io.Writer, f Format, t Table) error {
+	switch f {
+	case CSV:
+		return writeCSV(w, t)
+	case Markdown:
+		return writeMarkdown(w, t)
+	default:
+		return writeText(w, t)
+	}
+}
+
+func writeCSV(w io.Writer, t Table) error {
+	// The BOM makes Excel read UTF-8 instead of the local code page.
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	header := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = c.Key
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	row := make([]string, len(t.Columns))
+	for _, r := range t.Rows {
+		row = row[:0]
+		for _, cell := range r {
+			row = append(row, csvCell(cell))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell defuses a cell that a spreadsheet would run as a formula (a
+// course or room name starting with "=" or "@" from upstream): a leading
+// apostrophe makes Excel and LibreOffice show it as text.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func writeMarkdown(w io.Writer, t Table) error {
+	bw := bufio.NewWriter(w)
+	if t.Title != "" {
+		bw.WriteString("**" + escapeMarkdown(t.Title) + "**\n\n")
+	}
+	for _, n := range t.Notes {
+		bw.WriteString("> " + escapeMarkdown(n) + "\n")
+	}
+	if len(t.Notes) > 0 {
+		bw.WriteString("\n")
+	}
+	cells := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		cells[i] = escapeMarkdown(c.Label)
+	}
+	bw.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	for i := range cells {
+		cells[i] = "---"
+	}
+	bw.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	for _, row := range t.Rows {
+		for i := range cells {
+			cells[i] = ""
+			if i < len(row) {
+				cells[i] = escapeMarkdown(row[i])
+			}
+		}
+		bw.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return bw.Flush()
+}
+
+func escapeMarkdown(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>").Replace(s)
+}
+
+// writeText pads columns by display width, counting CJK characters as two
+// cells so tables line up in a terminal.
+func writeText(w io.Writer, t Table) error {
+	bw := bufio.NewWriter(w)
+	if t.Title != "" {
+		bw.WriteString(t.Title + "\n")
+	}
+	for _, n := range t.Notes {
+		bw.WriteString(n + "\n")
+	}
+	if t.Title != "" || len(t.Notes) > 0 {
+		bw.WriteString("\n")
+	}
+	widths := make([]int, len(t.Columns))
+	for i, c := range t.Columns {
+		widths[i] = Width(c.Label)
+	}
+	for _, row := range t.Rows {
+		for i := range widths {
+			if i < len(row) {
+				widths[i] = max(widths[i], Width(flatten(row[i])))
+			}
+		}
+	}
+	line := func(cells func(i int) string) {
+		var b strings.Builder
+		for i := range widths {
+			s := cells(i)
+			b.WriteString(s)
+			if i < len(widths)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-Width(s)+2))
+			}
+		}
+		bw.WriteString(strings.TrimRight(b.String(), " ") + "\n")
+	}
+	line(func(i int) string { return t.Columns[i].Label })
+	line(func(i int) string { return strings.Repeat("-", widths[i]) })
+	for _, row := range t.Rows {
+		line(func(i int) string {
+			if i < len(row) {
+				return flatten(row[i])
+			}
+			return ""
+		})
+	}
+	return bw.Flush()
+}
+
+func flatten(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\t", " ").Replace(s)
+}
+
+// Width is the number of terminal cells s occupies.
+func Width(s string) int {
+	n := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		n += runeWidth(r)
+	}
+	return n
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case r == 0 || unicode.Is(unicode.Mn, r):
+		return 0
+	case textwidth.IsWide(r):
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"线性代数", "线性代数"},
+		{"A-101", "A-101"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+1", "'+1"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"\rcmd", "'\rcmd"},
+		{" =1", " =1"},
+		{"＝1", "＝1"}, // full-width equals sign is not a formula
+	}
+	for _, tt := range tests {
+		if got := csvCell(tt.in); got != tt.want {
+			t.Errorf("csvCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCSVEscapesCellsNotHeader(t *testing.T) {
+	var buf bytes.Buffer
+	tbl := Table{
+		Columns: []Column{{Key: "courseName"}, {Key: "classroomName"}},
+		Rows:    [][]string{{"=cmd|' /C calc'!A0", "教一楼 102"}},
+	}
+	if err := Write(&buf, CSV, tbl); err != nil {
+		t.Fatal(err)
+	}
+	want := "\uFEFFcourseName,classroomName\n'=cmd|' /C calc'!A0,教一楼 102\n"
+	if got := buf.String(); got != want {
+		t.Errorf("CSV = %q, want %q", got, want)
+	}
+}
+
+func TestWidth(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"线性代数", 8},
+		{"A-101 教室", 10},
+		{"ｶﾀｶﾅ", 4},       // half-width katakana
+		{"ＡＢ", 4},         // full-width Latin
+		{"한글", 4},         // Hangul syllables
+		{"“引号”…", 10},     // ambiguous punctuation is wide in Chinese text
+		{"·×", 4},         // middle dot and multiplication sign
+		{"e\u0301", 1},    // combining accent takes no cell
+		{"〿", 1},          // U+303F half fill space is narrow
+		{"\U00020000", 2}, // CJK extension B
+		{"Ω", 1},          // Greek stays narrow
+		{strings.Repeat("课", 3), 6},
+	}
+	for _, tt := range tests {
+		if got := Width(tt.in); got != tt.want {
+			t.Errorf("Width(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
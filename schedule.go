@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"LoginTest/ical"
+	"LoginTest/models"
+)
+
+// ------------------------------
+// Merged schedule
+// ------------------------------
+// Course responses keep "result" as the raw upstream models.CourseRecord
+// list for existing clients and add "schedule": upstream courses and the
+// user's own entries in one list ordered by begin time, each tagged with
+// its source.
+
+const (
+	sourceCourse   = "course"
+	sourcePersonal = "personal"
+	sourceCalendar = "calendar" // imported ICS, see feeds.go
+)
+
+// scheduleItem is one entry of the merged schedule.
+type scheduleItem struct {
+	Source    string               `json:"source"`
+	ID        string               `json:"id"`
+	Title     string               `json:"title"`
+	Location  string               `json:"location,omitempty"`
+	Teacher   string               `json:"teacher,omitempty"`
+	Category  string               `json:"category,omitempty"`
+	Notes     string               `json:"notes,omitempty"`
+	Calendar  string               `json:"calendar,omitempty"` // name of the imported calendar
+	Begin     time.Time            `json:"begin"`
+	End       time.Time            `json:"end"`
+	AllDay    bool                 `json:"allDay,omitempty"`
+	Recurring bool                 `json:"recurring,omitempty"`
+	Course    *models.CourseRecord `json:"course,omitempty"`
+}
+
+// courseItems converts upstream courses; records with unparsable times
+// are left out of the schedule (they stay in "result").
+func courseItems(courses []models.CourseRecord) []scheduleItem {
+	var items []scheduleItem
+	for i := range courses {
+		c := courses[i]
+		begin, err1 := models.ParseCourseTime(c.ClassBeginTime)
+		end, err2 := models.ParseCourseTime(c.ClassEndTime)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		items = append(items, scheduleItem{
+			Source:   sourceCourse,
+			ID:       courseKey(c),
+			Title:    c.CourseName,
+			Location: strings.TrimSpace(c.TeachBuildName + " " + c.ClassroomName),
+			Teacher:  c.TeacherName,
+			Begin:    begin,
+			End:      end,
+			Course:   &c,
+		})
+	}
+	return items
+}
+
+// extraItems lists the non-upstream entries of uid overlapping [from, to).
+func extraItems(uid string, from, to time.Time) []scheduleItem {
+	return append(personalItems(uid, from, to), feedItems(uid, from, to)...)
+}
+
+// daySchedule merges the courses of one day (YYYYMMDD) with uid's entries.
+func daySchedule(uid, dateStr string, courses []models.CourseRecord) []scheduleItem {
+	items := courseItems(courses)
+	if day, err := time.ParseInLocation("20060102", dateStr, academicLoc); err == nil {
+		items = append(items, extraItems(uid, day, day.AddDate(0, 0, 1))...)
+	}
+	sortSchedule(items)
+	if items == nil {
+		items = []scheduleItem{}
+	}
+	return items
+}
+
+// sortSchedule orders by begin time; all-day entries come first.
+func sortSchedule(items []scheduleItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].AllDay != items[j].AllDay {
+			return items[i].AllDay
+		}
+		return items[i].Begin.Before(items[j].Begin)
+	})
+}
+
+// handleCalendarExport serves the merged schedule as iCalendar.
+// GET /courses/calendar.ics?from=<date expr>&to=<date expr> (default two weeks from today, at most 31 days)
+// ?personal=0 leaves out non-upstream entries.
+func handleCalendarExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+
+	q := r.URL.Query()
+	from, err := resolveDate(q.Get("from"), clock())
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to := from.AddDate(0, 0, 13)
+	if v := strings.TrimSpace(q.Get("to")); v != "" {
+		if to, err = resolveDate(v, clock()); err != nil {
+			http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "to is before from", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) >= maxRangeDays*24*time.Hour {
+		http.Error(w, fmt.Sprintf("range is limited to %d days", maxRangeDays), http.StatusBadRequest)
+		return
+	}
+
+	days, err := buildDays(sess, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	withExtras := q.Get("personal") != "0"
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="courses.ics"`)
+	cw := ical.NewWriter(w, "-//UCASCourseLogin//Schedule//ZH", "课程表")
+	// Entries spanning midnight show up on every day they touch.
+	seen := map[string]bool{}
+	for _, day := range days {
+		for _, item := range day.Schedule {
+			if item.Source != sourceCourse && !withExtras {
+				continue
+			}
+			e := item.icalEvent()
+			if seen[e.UID] {
+				continue
+			}
+			seen[e.UID] = true
+			cw.WriteEvent(e)
+		}
+	}
+	_ = cw.Close()
+}
+
+// icalEvent converts an item for export; X-SOURCE carries the source.
+func (item scheduleItem) icalEvent() ical.Event {
+	e := ical.Event{
+		UID:      item.ID + "-" + item.Begin.Format("20060102T1504") + "@" + item.Source,
+		Summary:  item.Title,
+		Location: item.Location,
+		Start:    item.Begin,
+		End:      item.End,
+		AllDay:   item.AllDay,
+		Props:    []ical.Property{{Name: "X-SOURCE", Value: item.Source}},
+	}
+	var notes []string
+	if item.Teacher != "" {
+		notes = append(notes, "教师："+item.Teacher)
+	}
+	if item.Notes != "" {
+		notes = append(notes, item.Notes)
+	}
+	e.Description = strings.Join(notes, "\n")
+	e.Categories = []string{item.Source}
+	if item.Calendar != "" {
+		e.Categories = append(e.Categories, item.Calendar)
+	}
+	if item.Category != "" {
+		e.Categories = append(e.Categories, item.Category)
+	}
+	return e
+}
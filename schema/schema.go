@@ -0,0 +1,170 @@
+// Package schema fingerprints the shape of upstream JSON responses and
+// compares it against the baseline bundled with the binary, so changes of
+// the iclass API show up before users report broken pages.
+package schema
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:embed baseline.json
+var baselineJSON []byte
+
+// Shape maps a field path ("result[].classBeginTime") to its JSON type:
+// string, number, bool, null, object or array. A path that was seen with
+// several types holds them joined with "|", e.g. "number|string".
+type Shape map[string]string
+
+// Baseline returns the bundled shapes keyed by endpoint (login, schedule, sign).
+func Baseline() (map[string]Shape, error) {
+	var b map[string]Shape
+	if err := json.Unmarshal(baselineJSON, &b); err != nil {
+		return nil, fmt.Errorf("schema: bad bundled baseline: %w", err)
+	}
+	return b, nil
+}
+
+// Fingerprint computes the shape of a JSON document. Array elements are
+// merged under "<path>[]".
+func Fingerprint(data []byte) (Shape, error) {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	s := Shape{}
+	s.walk(raw, "")
+	return s, nil
+}
+
+func (s Shape) walk(v any, path string) {
+	switch x := v.(type) {
+	case map[string]any:
+		if path != "" {
+			s.add(path, "object")
+		}
+		for k, item := range x {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			s.walk(item, p)
+		}
+	case []any:
+		s.add(path, "array")
+		for _, item := range x {
+			s.walk(item, path+"[]")
+		}
+	case string:
+		s.add(path, "string")
+	case float64:
+		s.add(path, "number")
+	case bool:
+		s.add(path, "bool")
+	case nil:
+		s.add(path, "null")
+	}
+}
+
+func (s Shape) add(path, typ string) {
+	old, ok := s[path]
+	if !ok {
+		s[path] = typ
+		return
+	}
+	types := strings.Split(old, "|")
+	for _, t := range types {
+		if t == typ {
+			return
+		}
+	}
+	types = append(types, typ)
+	sort.Strings(types)
+	s[path] = strings.Join(types, "|")
+}
+
+// Drift kinds.
+const (
+	KindAdded   = "added"   // observed but not in the baseline
+	KindMissing = "missing" // in the baseline, parent observed, field absent
+	KindRetyped = "retyped" // present with a different type
+)
+
+// Drift is one difference between baseline and observation.
+type Drift struct {
+	Kind     string `json:"kind"`
+	Path     string `json:"path"`
+	Expected string `json:"expected,omitempty"`
+	Observed string `json:"observed,omitempty"`
+}
+
+// Key identifies a drift independently of when it was seen.
+func (d Drift) Key() string { return d.Kind + " " + d.Path + " " + d.Expected + " " + d.Observed }
+
+// Compare lists how observed deviates from baseline. A field only counts
+// as missing when its parent object was observed, so an empty course list
+// does not report every course field as gone. null is accepted for any
+// expected type.
+func Compare(baseline, observed Shape) []Drift {
+	var out []Drift
+	for path, typ := range observed {
+		want, ok := baseline[path]
+		if !ok {
+			out = append(out, Drift{Kind: KindAdded, Path: path, Observed: typ})
+			continue
+		}
+		if !compatible(want, typ) {
+			out = append(out, Drift{Kind: KindRetyped, Path: path, Expected: want, Observed: typ})
+		}
+	}
+	for path, want := range baseline {
+		if _, ok := observed[path]; ok {
+			continue
+		}
+		if strings.HasSuffix(path, "[]") {
+			// An empty list has no elements, that is not a missing field.
+			continue
+		}
+		if parent := parentPath(path); parent == "" || containsType(observed[parent], "object") {
+			out = append(out, Drift{Kind: KindMissing, Path: path, Expected: want})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Kind < out[j].Kind
+	})
+	return out
+}
+
+func compatible(want, got string) bool {
+	for _, t := range strings.Split(got, "|") {
+		if t != "null" && !containsType(want, t) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsType(types, typ string) bool {
+	for _, t := range strings.Split(types, "|") {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// parentPath strips the last segment: "result[].id" -> "result[]",
+// "result.id" -> "result", "STATUS" -> "".
+func parentPath(path string) string {
+	i := strings.LastIndex(path, ".")
+	if i < 0 {
+		return ""
+	}
+	return path[:i]
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"LoginTest/schema"
+)
+
+// ------------------------------
+// Upstream schema drift report
+// ------------------------------
+// Every upstream response that reaches decodeUpstream (and the raw sign-in
+// answer) is fingerprinted and compared with the baseline in
+// schema/baseline.json. Deviations are accumulated per endpoint and shown
+// on /admin/upstream-schema.
+
+// driftRecord is one accumulated deviation.
+type driftRecord struct {
+	schema.Drift
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int       `json:"count"`
+}
+
+// endpointSchema is the state of one upstream endpoint.
+type endpointSchema struct {
+	Samples      int                     `json:"samples"`
+	LastObserved time.Time               `json:"lastObserved"`
+	LastShape    schema.Shape            `json:"lastShape,omitempty"`
+	drift        map[string]*driftRecord // keyed by Drift.Key()
+}
+
+var (
+	schemaBaseline   map[string]schema.Shape
+	schemaState      = map[string]*endpointSchema{}
+	schemaStateMu    sync.Mutex
+	schemaLoadFailed bool
+)
+
+func init() {
+	b, err := schema.Baseline()
+	if err != nil {
+		log.Printf("%v", err)
+		schemaLoadFailed = true
+		return
+	}
+	schemaBaseline = b
+}
+
+// observeSchema fingerprints body and records its drift for endpoint.
+// Error replies have a shape of their own (no result, a message instead),
+// so only successful ones are compared with the baseline.
+func observeSchema(endpoint string, status int, body []byte) {
+	if schemaLoadFailed || !upstreamSucceeded(status, body) {
+		return
+	}
+	shape, err := schema.Fingerprint(body)
+	if err != nil {
+		return
+	}
+	baseline, ok := schemaBaseline[endpoint]
+	if !ok {
+		return
+	}
+	drift := schema.Compare(baseline, shape)
+	now := time.Now()
+
+	schemaStateMu.Lock()
+	defer schemaStateMu.Unlock()
+	st, ok := schemaState[endpoint]
+	if !ok {
+		st = &endpointSchema{drift: map[string]*driftRecord{}}
+		schemaState[endpoint] = st
+	}
+	st.Samples++
+	st.LastObserved = now
+	st.LastShape = shape
+	for _, d := range drift {
+		rec, seen := st.drift[d.Key()]
+		if !seen {
+			rec = &driftRecord{Drift: d, FirstSeen: now}
+			st.drift[d.Key()] = rec
+			log.Printf("upstream schema drift on %s: %s %s (expected %q, observed %q)",
+				endpoint, d.Kind, d.Path, d.Expected, d.Observed)
+		}
+		rec.LastSeen = now
+		rec.Count++
+	}
+}
+
+// handleUpstreamSchema reports accumulated drift per endpoint.
+// GET /admin/upstream-schema[?shape=1] -> { endpoints: { login: {...}, ... } }
+func handleUpstreamSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	withShape := r.URL.Query().Get("shape") == "1"
+
+	type endpointReport struct {
+		Samples      int            `json:"samples"`
+		LastObserved *time.Time     `json:"lastObserved,omitempty"`
+		Compatible   bool           `json:"compatible"`
+		Drift        []*driftRecord `json:"drift"`
+		LastShape    schema.Shape   `json:"lastShape,omitempty"`
+		Baseline     schema.Shape   `json:"baseline,omitempty"`
+	}
+	out := map[string]endpointReport{}
+
+	schemaStateMu.Lock()
+	for endpoint, baseline := range schemaBaseline {
+		rep := endpointReport{Compatible: true, Drift: []*driftRecord{}}
+		if st, ok := schemaState[endpoint]; ok {
+			t := st.LastObserved
+			rep.Samples = st.Samples
+			rep.LastObserved = &t
+			for _, d := range st.drift {
+				copied := *d
+				rep.Drift = append(rep.Drift, &copied)
+				// New fields are harmless; missing or retyped ones break parsing.
+				if d.Kind != schema.KindAdded {
+					rep.Compatible = false
+				}
+			}
+			if withShape {
+				rep.LastShape = st.LastShape
+			}
+		}
+		if withShape {
+			rep.Baseline = baseline
+		}
+		sort.Slice(rep.Drift, func(i, j int) bool {
+			if rep.Drift[i].Path != rep.Drift[j].Path {
+				return rep.Drift[i].Path < rep.Drift[j].Path
+			}
+			return rep.Drift[i].Kind < rep.Drift[j].Kind
+		})
+		out[endpoint] = rep
+	}
+	schemaStateMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"endpoints": out,
+	})
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"LoginTest/events"
+)
+
+// ------------------------------
+// Semesters and teaching weeks
+// ------------------------------
+// A semester starts on the Monday of teaching week 1 and lasts Weeks weeks.
+// Semester starts must be configured, in state/semesters.json or with
+// SEMESTER_START for a quick setup. The iclass schedule API carries no
+// start date, and the first day a SemesterID happens to be fetched may be
+// mid-term, so fetched courses only teach us the semester's ID and name;
+// such learned semesters have no start and teachingWeek stays null until
+// an operator configures them.
+//
+// state/semesters.json:
+//   [{"id":"72","name":"2026秋季","start":"2026-09-07","weeks":20,
+//     "breaks":[{"name":"国庆","start":"2026-10-01","end":"2026-10-07","pausesWeeks":false}]}]
+
+const (
+	semestersFile        = "semesters.json"
+	learnedSemestersFile = "semesters_learned.json"
+	defaultSemesterWeeks = 20
+)
+
+// DateRange is an inclusive range of days (YYYY-MM-DD).
+type DateRange struct {
+	Name  string `json:"name,omitempty"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// PausesWeeks: whole weeks inside the break are not counted as teaching
+	// weeks (week numbering resumes after it).
+	PausesWeeks bool `json:"pausesWeeks,omitempty"`
+}
+
+// Semester describes one term.
+type Semester struct {
+	ID     string      `json:"id"`
+	Name   string      `json:"name"`
+	Start  string      `json:"start"` // YYYY-MM-DD, normalised to a Monday
+	Weeks  int         `json:"weeks"`
+	Breaks []DateRange `json:"breaks,omitempty"`
+}
+
+// startDate parses Start and moves it back to the Monday of its week.
+func (s Semester) startDate() (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", s.Start, academicLoc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.AddDate(0, 0, -daysFromMonday(t.Weekday())), nil
+}
+
+// pausedWeeks returns the Mondays of weeks entirely covered by a break
+// that pauses numbering.
+func (s Semester) pausedWeeks() map[string]bool {
+	paused := map[string]bool{}
+	for _, b := range s.Breaks {
+		if !b.PausesWeeks {
+			continue
+		}
+		start, err1 := time.ParseInLocation("2006-01-02", b.Start, academicLoc)
+		end, err2 := time.ParseInLocation("2006-01-02", b.End, academicLoc)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		monday := start.AddDate(0, 0, (7-daysFromMonday(start.Weekday()))%7)
+		for ; !monday.AddDate(0, 0, 6).After(end); monday = monday.AddDate(0, 0, 7) {
+			paused[monday.Format("20060102")] = true
+		}
+	}
+	return paused
+}
+
+// InBreak reports the break that contains day, if any.
+func (s Semester) InBreak(day time.Time) (DateRange, bool) {
+	d := day.In(academicLoc).Format("2006-01-02")
+	for _, b := range s.Breaks {
+		if d >= b.Start && d <= b.End {
+			return b, true
+		}
+	}
+	return DateRange{}, false
+}
+
+// TeachingWeek returns the 1-based teaching week of day, or 0 when day is
+// outside the semester or inside a week that does not count.
+func (s Semester) TeachingWeek(day time.Time) int {
+	start, err := s.startDate()
+	if err != nil {
+		return 0
+	}
+	day = day.In(academicLoc)
+	d := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, academicLoc)
+	if d.Before(start) {
+		return 0
+	}
+	paused := s.pausedWeeks()
+	week := 0
+	for monday := start; !monday.After(d); monday = monday.AddDate(0, 0, 7) {
+		if paused[monday.Format("20060102")] {
+			if !monday.AddDate(0, 0, 7).After(d) {
+				continue
+			}
+			return 0
+		}
+		week++
+	}
+	if week > s.weeks() {
+		return 0
+	}
+	return week
+}
+
+// WeekStart returns the Monday of teaching week n.
+func (s Semester) WeekStart(n int) (time.Time, error) {
+	if s.Start == "" {
+		return time.Time{}, fmt.Errorf("semester %s has no start date, configure it in %s", s.Name, semestersFile)
+	}
+	start, err := s.startDate()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("semester %s has invalid start %q", s.ID, s.Start)
+	}
+	if n < 1 || n > s.weeks() {
+		return time.Time{}, fmt.Errorf("semester %s has no teaching week %d", s.Name, n)
+	}
+	paused := s.pausedWeeks()
+	monday := start
+	for week := 0; ; monday = monday.AddDate(0, 0, 7) {
+		if paused[monday.Format("20060102")] {
+			continue
+		}
+		week++
+		if week == n {
+			return monday, nil
+		}
+	}
+}
+
+// End is the last day (Sunday) of the semester.
+func (s Semester) End() time.Time {
+	start, err := s.startDate()
+	if err != nil {
+		return time.Time{}
+	}
+	return start.AddDate(0, 0, 7*(s.weeks()+len(s.pausedWeeks()))-1)
+}
+
+func (s Semester) weeks() int {
+	if s.Weeks > 0 {
+		return s.Weeks
+	}
+	return defaultSemesterWeeks
+}
+
+// contains reports whether day falls between start and End.
+func (s Semester) contains(day time.Time) bool {
+	start, err := s.startDate()
+	if err != nil {
+		return false
+	}
+	return !day.Before(start) && !day.After(s.End().AddDate(0, 0, 1).Add(-time.Nanosecond))
+}
+
+var (
+	configuredSemesters []Semester
+	learnedSemesters    = map[string]Semester{}
+	semestersMu         sync.RWMutex
+)
+
+// loadSemesters reads configured and learned semesters and starts learning
+// from CoursesFetched events.
+func loadSemesters() {
+	semestersMu.Lock()
+	if err := loadDataFile(semestersFile, &configuredSemesters); err != nil {
+		log.Printf("load semesters failed: %v", err)
+	}
+	if v := os.Getenv("SEMESTER_START"); v != "" {
+		configuredSemesters = append(configuredSemesters, Semester{ID: "env", Name: "SEMESTER_START", Start: v})
+	}
+	for _, s := range configuredSemesters {
+		if _, err := s.startDate(); err != nil {
+			log.Printf("semester %s: invalid start %q", s.ID, s.Start)
+		}
+	}
+	// Older versions stored starts guessed from fetched courses; only
+	// configured semesters have a start, so those are dropped.
+	var stored map[string]Semester
+	if err := loadDataFile(learnedSemestersFile, &stored); err != nil {
+		log.Printf("load learned semesters failed: %v", err)
+	}
+	learnedSemesters = map[string]Semester{}
+	for id, st := range stored {
+		st.Start = ""
+		learnedSemesters[id] = st
+	}
+	semestersMu.Unlock()
+
+	bus.Register(events.HookFunc{HookName: "semesters", Fn: learnSemesters})
+}
+
+// learnSemesters records the ID and name of each SemesterID seen in
+// fetched courses.
+func learnSemesters(ev events.Event) {
+	fetched, ok := ev.Data.(events.CoursesFetched)
+	if !ok {
+		return
+	}
+	semestersMu.Lock()
+	defer semestersMu.Unlock()
+	changed := false
+	for _, c := range fetched.Courses {
+		if c.SemesterID == "" || configuredSemester(c.SemesterID) {
+			continue
+		}
+		s, known := learnedSemesters[c.SemesterID]
+		if known && c.SemesterName == s.Name {
+			continue
+		}
+		learnedSemesters[c.SemesterID] = Semester{ID: c.SemesterID, Name: c.SemesterName, Weeks: defaultSemesterWeeks}
+		changed = true
+	}
+	if changed {
+		if err := saveDataFile(learnedSemestersFile, learnedSemesters); err != nil {
+			log.Printf("save learned semesters failed: %v", err)
+		}
+	}
+}
+
+// configuredSemester reports whether id is configured; caller holds semestersMu.
+func configuredSemester(id string) bool {
+	for _, s := range configuredSemesters {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// allSemesters lists configured semesters first, then learned ones, each
+// group ordered by start.
+func allSemesters() []Semester {
+	semestersMu.RLock()
+	defer semestersMu.RUnlock()
+	out := append([]Semester{}, configuredSemesters...)
+	var learned []Semester
+	for _, s := range learnedSemesters {
+		learned = append(learned, s)
+	}
+	sort.Slice(learned, func(i, j int) bool { return learned[i].Start < learned[j].Start })
+	return append(out, learned...)
+}
+
+// semesterFor returns the semester containing day. Without one it falls
+// back to the most recent semester that started before day.
+func semesterFor(day time.Time) (Semester, bool) {
+	var latest Semester
+	found := false
+	for _, s := range allSemesters() {
+		if s.contains(day) {
+			return s, true
+		}
+		start, err := s.startDate()
+		if err != nil || start.After(day) {
+			continue
+		}
+		if !found || s.Start > latest.Start {
+			latest, found = s, true
+		}
+	}
+	return latest, found
+}
+
+// weekInfo is the teaching-week annotation added to course responses.
+type weekInfo struct {
+	TeachingWeek int    `json:"teachingWeek"`
+	SemesterID   string `json:"semesterId,omitempty"`
+	SemesterName string `json:"semesterName,omitempty"`
+	Break        string `json:"break,omitempty"`
+}
+
+// teachingWeekInfo annotates a date (YYYYMMDD); ok is false without any
+// known semester.
+func teachingWeekInfo(dateStr string) (weekInfo, bool) {
+	day, err := time.ParseInLocation("20060102", dateStr, academicLoc)
+	if err != nil {
+		return weekInfo{}, false
+	}
+	s, ok := semesterFor(day)
+	if !ok {
+		return weekInfo{}, false
+	}
+	info := weekInfo{
+		TeachingWeek: s.TeachingWeek(day),
+		SemesterID:   s.ID,
+		SemesterName: s.Name,
+	}
+	if b, ok := s.InBreak(day); ok {
+		info.Break = b.Name
+		if info.Break == "" {
+			info.Break = "break"
+		}
+	}
+	return info, true
+}
+
+// annotateWeek adds teachingWeek and semester fields to a course payload.
+func annotateWeek(payload map[string]any, dateStr string) {
+	info, ok := teachingWeekInfo(dateStr)
+	if !ok {
+		payload["teachingWeek"] = nil
+		return
+	}
+	payload["teachingWeek"] = info.TeachingWeek
+	payload["semester"] = info
+}
+
+// handleSemesters lists known semesters and where today falls.
+// GET /semesters -> { semesters: [...], today: { teachingWeek, semesterId, ... } }
+func handleSemesters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	type semesterView struct {
+		Semester
+		End string `json:"end"`
+	}
+	list := []semesterView{}
+	for _, s := range allSemesters() {
+		end := ""
+		if e := s.End(); !e.IsZero() {
+			end = e.Format("2006-01-02")
+		}
+		list = append(list, semesterView{Semester: s, End: end})
+	}
+	payload := map[string]any{
+		"semesters": list,
+	}
+	if info, ok := teachingWeekInfo(academicToday()); ok {
+		payload["today"] = info
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(payload)
+}
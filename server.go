@@ -17,7 +17,9 @@ import (
 	"time"
 
 	"LoginTest/auth"
+	"LoginTest/events"
 	"LoginTest/models"
+	"LoginTest/render"
 )
 
 // ------------------------------
@@ -96,6 +98,7 @@ func getSession(r *http.Request) (*Session, string, bool) {
 		sessionsMu.Lock()
 		delete(sessions, sid)
 		sessionsMu.Unlock()
+		publishSessionExpired(sess)
 		return nil, "", false
 	}
 	return sess, sid, true
@@ -111,29 +114,49 @@ func touchSession(sid string) {
 }
 
 func main() {
-	http.HandleFunc("/login", handleLogin)
-	http.HandleFunc("/me", handleMe)
-	http.HandleFunc("/courses/today", handleCoursesToday)
-	http.HandleFunc("/get_courses", handleGetCourses)
-	http.HandleFunc("/api/sign-in", handleSignIn)
-
-	// Backward-compatible legacy endpoint
-	http.HandleFunc("/getTodayCourse", handleGetTodayCourse)
-	http.HandleFunc("/logout", handleLogout)
-
-	// 提供静态文件：/web 与 /data
-	http.Handle("/web/", http.StripPrefix("/web/", http.FileServer(http.Dir("web"))))
-	http.Handle("/data/", http.StripPrefix("/data/", http.FileServer(http.Dir("data"))))
-	http.HandleFunc("/web/main.css", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "web/main.css")
-	})
+	loadAcademicTZ()
+
+	// JSON 与导出接口位于 /api/v1，旧路径作为弃用别名保留（见 api.go）
+	// 使用独立的 mux：导入 expvar 会在 DefaultServeMux 上注册公开的 /debug/vars
+	mux := http.NewServeMux()
+	loadAPIConfig()
+	registerAPI(mux)
+	mux.HandleFunc("/ui/", handleUIDay)
+	mux.HandleFunc("/ui/login", handleUILogin)
+	mux.HandleFunc("/ui/logout", handleUILogout)
+	mux.HandleFunc("/ui/week", handleUIWeek)
+
+	// 提供静态文件：/web（内嵌于二进制，见 assets.go）与 /data（仅 courses_*.json 演示缓存）
+	mux.HandleFunc("/web/", handleWebAsset)
+	mux.HandleFunc("/data/", handleCourseDump)
+
+	// expvar 指标仅对管理员开放（见 admin.go）
+	mux.HandleFunc("/debug/vars", handleDebugVars)
+
+	loadStateConfig()
+	loadWebAssets()
+	startSessionJanitor()
+	loadWebhooks()
+	loadChanges()
+	loadSemesters()
+	loadAcademicCalendar()
+	loadPersonalEvents()
+	loadWalkingConfig()
+	loadTimetableConfig()
+	loadTimetableImages()
+	loadFeedFetchConfig()
+	loadCalendarFeeds()
+	startFeedScheduler()
+	startReminderScheduler()
+	loadDigestConfig()
+	startDigestScheduler()
 
 	addr := ":8081"
 	if fromEnv := os.Getenv("PORT"); fromEnv != "" {
 		addr = ":" + fromEnv
 	}
 	log.Printf("listening on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	log.Fatal(http.ListenAndServe(addr, compressHandler(mux)))
 }
 
 // handleSignIn proxies the sign-in request to the upstream service.
@@ -217,6 +240,13 @@ func handleSignIn(w http.ResponseWriter, r *http.Request) {
 
 	// Log the upstream response for debugging
 	log.Printf("Upstream sign-in response for timeTableId %s: %s", timeTableID, string(bodyBytes))
+	observeSchema("sign", resp.StatusCode, bodyBytes)
+	bus.Publish(events.SignAttempted{
+		UID:         sess.UID,
+		TimeTableID: timeTableID,
+		Status:      resp.StatusCode,
+		Response:    truncate(string(bodyBytes), 512),
+	})
 
 	w.Write(bodyBytes)
 }
@@ -235,14 +265,50 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid json body", http.StatusBadRequest)
 		return
 	}
+	sid, user, lerr := login(params)
+	if lerr != nil {
+		if lerr.Raw != nil {
+			// 登录响应不是预期结构，透传原始响应
+			for k, vs := range lerr.Header {
+				for _, v := range vs {
+					w.Header().Add(k, v)
+				}
+			}
+			w.WriteHeader(lerr.Status)
+			_, _ = w.Write(lerr.Raw)
+			return
+		}
+		http.Error(w, lerr.Message, lerr.Status)
+		return
+	}
+
+	// 设置 Cookie 并返回用户信息
+	setSessionCookie(w, sid)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"user": user,
+	})
+}
+
+// loginError is a failed login. Raw holds an upstream answer that is not a
+// login response; the JSON API passes it through unchanged.
+type loginError struct {
+	Status  int
+	Message string
+	Header  http.Header
+	Raw     []byte
+}
+
+// login checks params against upstream and opens a local session, returning
+// its id. Shared by the JSON API and the HTML UI.
+func login(params auth.LoginParams) (string, auth.UserInfo, *loginError) {
 	params.Phone = strings.TrimSpace(params.Phone)
 	params.Password = strings.TrimSpace(params.Password)
 	params.UserLevel = strings.TrimSpace(params.UserLevel)
 	params.VerificationType = strings.TrimSpace(params.VerificationType)
 	params.VerificationURL = strings.TrimSpace(params.VerificationURL)
 	if params.Phone == "" || params.Password == "" {
-		http.Error(w, "phone and password required", http.StatusBadRequest)
-		return
+		return "", auth.UserInfo{}, &loginError{Status: http.StatusBadRequest, Message: "phone and password required"}
 	}
 	if params.UserLevel == "" {
 		params.UserLevel = defaultUserLevel
@@ -269,8 +335,7 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	req, err := http.NewRequest(http.MethodPost, target, bytes.NewBufferString(form.Encode()))
 	if err != nil {
-		http.Error(w, "build request failed", http.StatusInternalServerError)
-		return
+		return "", auth.UserInfo{}, &loginError{Status: http.StatusInternalServerError, Message: "build request failed"}
 	}
 
 	// 按示例设置请求头
@@ -283,37 +348,35 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		http.Error(w, "upstream request failed", http.StatusBadGateway)
-		return
+		bus.Publish(events.LoginFailed{Phone: maskID(params.Phone), Reason: "upstream request failed", Status: http.StatusBadGateway})
+		return "", auth.UserInfo{}, &loginError{Status: http.StatusBadGateway, Message: "upstream request failed"}
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		http.Error(w, "read upstream failed", http.StatusBadGateway)
-		return
+		bus.Publish(events.LoginFailed{Phone: maskID(params.Phone), Reason: "read upstream failed", Status: http.StatusBadGateway})
+		return "", auth.UserInfo{}, &loginError{Status: http.StatusBadGateway, Message: "read upstream failed"}
 	}
 
 	// 解析上游响应
 	var loginResp auth.LoginResponse
-	if err := json.Unmarshal(bodyBytes, &loginResp); err != nil {
-		// 登录响应不是预期结构，透传原始响应
-		for k, vs := range resp.Header {
-			for _, v := range vs {
-				w.Header().Add(k, v)
-			}
+	if err := decodeUpstream("login", resp.StatusCode, bodyBytes, &loginResp); err != nil {
+		bus.Publish(events.LoginFailed{Phone: maskID(params.Phone), Reason: "unexpected upstream response", Status: resp.StatusCode})
+		return "", auth.UserInfo{}, &loginError{
+			Status:  resp.StatusCode,
+			Message: "unexpected upstream response",
+			Header:  resp.Header,
+			Raw:     bodyBytes,
 		}
-		w.WriteHeader(resp.StatusCode)
-		_, _ = w.Write(bodyBytes)
-		return
 	}
 
 	// 从响应中提取用户与上游会话ID
 	uid := strings.TrimSpace(loginResp.Result.ID)
 	upSess := strings.TrimSpace(loginResp.Result.SessionID)
 	if uid == "" {
-		http.Error(w, "login failed: empty user id", http.StatusBadGateway)
-		return
+		bus.Publish(events.LoginFailed{Phone: maskID(params.Phone), Reason: "empty user id", Status: resp.StatusCode})
+		return "", auth.UserInfo{}, &loginError{Status: http.StatusBadGateway, Message: "login failed: empty user id"}
 	}
 	if upSess == "" {
 		// 某些环境可能不回传 sessionId，则回退到 legacy（不推荐，仅为兼容）
@@ -323,8 +386,7 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 	// 创建本地会话
 	sid, err := genToken()
 	if err != nil {
-		http.Error(w, "create session failed", http.StatusInternalServerError)
-		return
+		return "", auth.UserInfo{}, &loginError{Status: http.StatusInternalServerError, Message: "create session failed"}
 	}
 	sessionsMu.Lock()
 	sessions[sid] = &Session{
@@ -334,13 +396,8 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 		ExpiresAt:         time.Now().Add(sessionTTL),
 	}
 	sessionsMu.Unlock()
-
-	// 设置 Cookie 并返回用户信息
-	setSessionCookie(w, sid)
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]any{
-		"user": loginResp.Result,
-	})
+	bus.Publish(events.LoginSucceeded{UID: uid, UserName: displayName(loginResp.Result.RealName, loginResp.Result.UserName)})
+	return sid, loginResp.Result, nil
 }
 
 // handleMe returns current user info for active session.
@@ -352,6 +409,9 @@ func handleMe(w http.ResponseWriter, r *http.Request) {
 	}
 	// 延长会话有效期
 	touchSession(sid)
+	if notModified(w, r, payloadETag(sess.User)) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{
 		"user": sess.User,
@@ -359,9 +419,12 @@ func handleMe(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleCoursesToday gets today's courses by session and date.
-// Request: JSON { dateStr: "YYYYMMDD" } (dateStr optional -> defaults to today)
+// Request: JSON { dateStr: "YYYYMMDD" | date expression, normalized: bool } (dateStr optional -> defaults to today)
+// or GET /courses/today?dateStr=...&normalized=1, which answers If-None-Match with 304.
+// See dates.go for accepted expressions; the response echoes the resolved dateStr.
+// With normalized (or ?normalized=1) result holds models.Course instead of raw records.
 func handleCoursesToday(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -372,19 +435,17 @@ func handleCoursesToday(w http.ResponseWriter, r *http.Request) {
 	}
 	touchSession(sid)
 	var body struct {
-		DateStr string `json:"dateStr"`
+		DateStr    string `json:"dateStr"`
+		Normalized bool   `json:"normalized"`
 	}
-	_ = json.NewDecoder(r.Body).Decode(&body)
-	dateStr := strings.TrimSpace(body.DateStr)
-	if dateStr == "" {
-		dateStr = time.Now().Format("20060102")
+	if r.Method == http.MethodPost {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	} else {
+		body.DateStr = r.URL.Query().Get("dateStr")
 	}
-	if len(dateStr) != 8 {
-		http.Error(w, "invalid date format", http.StatusBadRequest)
-		return
-	}
-	if _, err := time.Parse("20060102", dateStr); err != nil {
-		http.Error(w, "invalid date value", http.StatusBadRequest)
+	dateStr, err := resolveDateStr(body.DateStr)
+	if err != nil {
+		http.Error(w, "invalid date: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -432,7 +493,7 @@ func handleCoursesToday(w http.ResponseWriter, r *http.Request) {
 
 	// 尝试解析为结构体
 	var today models.TodayCoursesResponse
-	if err := json.Unmarshal(bodyBytes, &today); err != nil {
+	if err := decodeUpstream("schedule", resp.StatusCode, bodyBytes, &today); err != nil {
 		// 解析失败则透传原始
 		for k, vs := range resp.Header {
 			for _, v := range vs {
@@ -443,11 +504,28 @@ func handleCoursesToday(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write(bodyBytes)
 		return
 	}
+	storeCourses(sess.UID, dateStr, resp.StatusCode, today)
 
 	// 添加 delta 到响应
 	response := map[string]any{
-		"result": today.Result,
-		"delta":  delta,
+		"result":   today.Result,
+		"delta":    delta,
+		"dateStr":  dateStr,
+		"schedule": daySchedule(sess.UID, dateStr, today.Result),
+	}
+	addTransitions(response)
+	annotateWeek(response, dateStr)
+	annotateCalendar(response, dateStr)
+	if body.Normalized || wantNormalized(r) {
+		addNormalized(response, today.Result)
+	}
+	f := render.Negotiate(r)
+	if resp.StatusCode == http.StatusOK && notModified(w, r, dayPayloadETag(response, today.Result, f)) {
+		return
+	}
+	if f != render.JSON {
+		writeDayTable(w, f, resp.StatusCode, sess.UID, dateStr, today.Result)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -457,6 +535,7 @@ func handleCoursesToday(w http.ResponseWriter, r *http.Request) {
 
 // handleGetCourses 兼容旧版前端：GET /get_courses
 // 响应格式：{ STATUS:"0"|"2", delta:int, result:[...] }
+// ?normalized=1 时 result 为规范化的 models.Course
 func handleGetCourses(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -468,9 +547,10 @@ func handleGetCourses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	touchSession(sid)
-	dateStr := strings.TrimSpace(r.URL.Query().Get("dateStr"))
-	if dateStr == "" {
-		dateStr = time.Now().Format("20060102")
+	dateStr, err := resolveDateStr(r.URL.Query().Get("dateStr"))
+	if err != nil {
+		http.Error(w, "invalid date: "+err.Error(), http.StatusBadRequest)
+		return
 	}
 	_, statusCode, today, delta, err := fetchCourses(sess, dateStr)
 	if err != nil {
@@ -479,18 +559,51 @@ func handleGetCourses(w http.ResponseWriter, r *http.Request) {
 	}
 
 	payload := map[string]any{
-		"STATUS": "0",
-		"delta":  delta,
-		"result": today.Result,
+		"STATUS":   "0",
+		"delta":    delta,
+		"result":   today.Result,
+		"dateStr":  dateStr,
+		"schedule": daySchedule(sess.UID, dateStr, today.Result),
 	}
 	if len(today.Result) == 0 {
 		payload["STATUS"] = "2"
 	}
+	addTransitions(payload)
+	annotateWeek(payload, dateStr)
+	annotateCalendar(payload, dateStr)
+	if wantNormalized(r) {
+		addNormalized(payload, today.Result)
+	}
+	f := render.Negotiate(r)
+	if statusCode == http.StatusOK && notModified(w, r, dayPayloadETag(payload, today.Result, f)) {
+		return
+	}
+	if f != render.JSON {
+		writeDayTable(w, f, statusCode, sess.UID, dateStr, today.Result)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	_ = json.NewEncoder(w).Encode(payload)
 }
 
+// wantNormalized reports whether the client asked for models.Course output.
+func wantNormalized(r *http.Request) bool {
+	v := strings.ToLower(r.URL.Query().Get("normalized"))
+	return v == "1" || v == "true"
+}
+
+// addNormalized replaces payload["result"] with normalized courses and lists
+// the fields that could not be parsed.
+func addNormalized(payload map[string]any, records []models.CourseRecord) {
+	courses, errs := models.NormalizeCourses(records)
+	payload["result"] = courses
+	payload["normalized"] = true
+	if len(errs) > 0 {
+		payload["normalizeErrors"] = errs
+	}
+}
+
 // fetchCourses 调用上游接口并返回格式化后的 JSON 字节、状态码、结构体和时间差
 func fetchCourses(sess *Session, dateStr string) ([]byte, int, models.TodayCoursesResponse, int64, error) {
 	target := "https://iclass.ucas.edu.cn:8181/app/course/get_stu_course_sched.action"
@@ -537,9 +650,10 @@ func fetchCourses(sess *Session, dateStr string) ([]byte, int, models.TodayCours
 	}
 
 	var today models.TodayCoursesResponse
-	if err := json.Unmarshal(bodyBytes, &today); err != nil {
+	if err := decodeUpstream("schedule", resp.StatusCode, bodyBytes, &today); err != nil {
 		return bodyBytes, resp.StatusCode, today, delta, nil
 	}
+	storeCourses(sess.UID, dateStr, resp.StatusCode, today)
 
 	if err := os.MkdirAll("data", 0755); err != nil {
 		log.Printf("mkdir data failed: %v", err)
@@ -555,6 +669,12 @@ func fetchCourses(sess *Session, dateStr string) ([]byte, int, models.TodayCours
 
 // handleLogout clears current session cookie and memory record.
 func handleLogout(w http.ResponseWriter, r *http.Request) {
+	clearSession(w, r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clearSession drops the session of r and expires its cookie.
+func clearSession(w http.ResponseWriter, r *http.Request) {
 	c, err := r.Cookie(cookieName)
 	if err == nil {
 		sessionsMu.Lock()
@@ -563,7 +683,6 @@ func handleLogout(w http.ResponseWriter, r *http.Request) {
 		// expire cookie
 		http.SetCookie(w, &http.Cookie{Name: cookieName, Value: "", Path: "/", Expires: time.Unix(0, 0), MaxAge: -1})
 	}
-	w.WriteHeader(http.StatusNoContent)
 }
 
 // ---------------------------------
@@ -618,7 +737,7 @@ func handleGetTodayCourse(w http.ResponseWriter, r *http.Request) {
 
 	// 尝试解析为结构体
 	var today models.TodayCoursesResponse
-	if err := json.Unmarshal(bodyBytes, &today); err != nil {
+	if err := decodeUpstream("schedule", resp.StatusCode, bodyBytes, &today); err != nil {
 		// 解析失败则透传原始
 		for k, vs := range resp.Header {
 			for _, v := range vs {
@@ -635,7 +754,7 @@ func handleGetTodayCourse(w http.ResponseWriter, r *http.Request) {
 	// 保存到本地 data/courses_<dateStr>.json
 	dateStr := params.DateStr
 	if dateStr == "" {
-		dateStr = time.Now().Format("20060102")
+		dateStr = academicToday()
 	}
 	if err := os.MkdirAll("data", 0755); err != nil {
 		log.Printf("mkdir data failed: %v", err)
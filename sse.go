@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"LoginTest/events"
+)
+
+// ------------------------------
+// Server-Sent Events stream
+// ------------------------------
+// Handlers publish typed events to bus; /events streams the events of the
+// session user to the browser. Reconnecting clients send Last-Event-ID and get whatever
+// they missed replayed from the bus history.
+
+const (
+	sseHeartbeat     = 15 * time.Second
+	sseBuffer        = 64
+	busHistorySize   = 1024
+	sessionSweepTick = time.Minute
+)
+
+var bus = events.NewBus(busHistorySize)
+
+// handleEvents streams bus events of the session user as text/event-stream.
+// Resume: Last-Event-ID header (or ?lastEventId= for clients that cannot set it).
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	touchSession(sid)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	var lastID uint64
+	lastRaw := r.Header.Get("Last-Event-ID")
+	if lastRaw == "" {
+		lastRaw = r.URL.Query().Get("lastEventId")
+	}
+	if lastRaw != "" {
+		if n, err := strconv.ParseUint(strings.TrimSpace(lastRaw), 10, 64); err == nil {
+			lastID = n
+		}
+	}
+
+	// Subscribe before replaying so nothing published in between is lost;
+	// duplicates are filtered by ID below.
+	sub := bus.Subscribe(sess.UID, sseBuffer)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: 3000\n\n")
+
+	if lastRaw != "" {
+		missed, complete := bus.Since(sess.UID, lastID)
+		if !complete {
+			// The client was away too long; tell it to reload everything.
+			fmt.Fprintf(w, "event: resync\ndata: {}\n\n")
+		}
+		for _, ev := range missed {
+			if err := writeSSE(w, ev); err != nil {
+				return
+			}
+			lastID = ev.ID
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprintf(w, ": ping %d\n\n", time.Now().Unix()); err != nil {
+				return
+			}
+			flusher.Flush()
+		case ev, ok := <-sub.C():
+			if !ok {
+				// Dropped as a slow consumer; the browser reconnects and resumes.
+				return
+			}
+			if ev.ID <= lastID {
+				continue
+			}
+			if ev.Type == events.TypeSessionExpired {
+				// Another session of the same user may have expired.
+				if _, _, alive := getSession(r); alive {
+					continue
+				}
+			}
+			if err := writeSSE(w, ev); err != nil {
+				return
+			}
+			lastID = ev.ID
+			flusher.Flush()
+			if ev.Type == events.TypeSessionExpired {
+				return
+			}
+		}
+	}
+}
+
+// writeSSE writes one event frame.
+func writeSSE(w http.ResponseWriter, ev events.Event) error {
+	data, err := json.Marshal(ev.Data)
+	if err != nil {
+		log.Printf("encode sse event %d failed: %v", ev.ID, err)
+		data = []byte("{}")
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+	return err
+}
+
+// startSessionJanitor removes expired sessions in the background so that
+// connected clients get a session.expired notice even when idle.
+func startSessionJanitor() {
+	go func() {
+		ticker := time.NewTicker(sessionSweepTick)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			var expired []*Session
+			sessionsMu.Lock()
+			for sid, sess := range sessions {
+				if now.After(sess.ExpiresAt) {
+					delete(sessions, sid)
+					expired = append(expired, sess)
+				}
+			}
+			sessionsMu.Unlock()
+			for _, sess := range expired {
+				publishSessionExpired(sess)
+			}
+		}
+	}()
+}
+
+// publishSessionExpired notifies the user's streams that a session ended.
+func publishSessionExpired(sess *Session) {
+	bus.Publish(events.SessionExpired{UID: sess.UID, ExpiredAt: sess.ExpiresAt})
+}
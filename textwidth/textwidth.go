@@ -0,0 +1,31 @@
+// Package textwidth decides which characters are wide: two cells in a
+// terminal, one em in the PDF and bitmap fonts, against one cell or half
+// an em for the rest. The text renderers, the timetable layout, the PDF
+// writer and the PNG renderer all measure with it, so their columns agree.
+package textwidth
+
+// ambiguous are characters Unicode gives an ambiguous width that Chinese
+// text uses as full-width punctuation (GB 2312 row 1); CJK fonts, including
+// STSong and the embedded bitmap font, draw them one em wide.
+var ambiguous = map[rune]bool{
+	'·': true, '×': true, '÷': true, '—': true, '‖': true,
+	'‘': true, '’': true, '“': true, '”': true, '…': true, '※': true,
+}
+
+// IsWide reports whether r is wide: East Asian Wide and Fullwidth
+// characters (CJK ideographs and punctuation, kana, Hangul, full-width
+// forms) and the punctuation above.
+func IsWide(r rune) bool {
+	if r < 0x1100 {
+		return ambiguous[r]
+	}
+	return r <= 0x115F || r == 0x2329 || r == 0x232A ||
+		(r >= 0x2E80 && r <= 0xA4CF && r != 0x303F) ||
+		(r >= 0xAC00 && r <= 0xD7A3) ||
+		(r >= 0xF900 && r <= 0xFAFF) ||
+		(r >= 0xFE30 && r <= 0xFE4F) ||
+		(r >= 0xFF00 && r <= 0xFF60) ||
+		(r >= 0xFFE0 && r <= 0xFFE6) ||
+		(r >= 0x20000 && r <= 0x3FFFD) ||
+		ambiguous[r]
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"LoginTest/layout"
+	"LoginTest/pdf"
+)
+
+// ------------------------------
+// Printable timetable
+// ------------------------------
+// /courses/week.pdf draws the classic grid (days across, periods down) of
+// one week on an A4 landscape page; the PNG exports in timetableimg.go
+// draw the same grid. The grid itself comes from package layout; this
+// file turns day views into its input and paints the result on a
+// timetableCanvas. Periods default to the UCAS timetable and can be
+// replaced with TIMETABLE_PERIODS="08:00-08:45,08:50-09:35,...".
+
+var timetablePeriods = layout.DefaultPeriods
+
+// loadTimetableConfig reads TIMETABLE_PERIODS.
+func loadTimetableConfig() {
+	if v := os.Getenv("TIMETABLE_PERIODS"); v != "" {
+		ps, err := layout.ParsePeriods(v)
+		if err != nil {
+			log.Printf("ignoring invalid TIMETABLE_PERIODS: %v", err)
+			return
+		}
+		timetablePeriods = ps
+	}
+}
+
+// timetableWeek resolves the days of a timetable request and builds its
+// layout input; on failure it has already answered the request. The week
+// is chosen by ?week= / ?date= as for /courses/week, a single day (when
+// single is set) by ?date=. ?personal=0 leaves out non-upstream entries.
+// The first day is returned alongside.
+func timetableWeek(w http.ResponseWriter, r *http.Request, single bool) (layout.Week, time.Time, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return layout.Week{}, time.Time{}, false
+	}
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return layout.Week{}, time.Time{}, false
+	}
+	touchSession(sid)
+
+	var from, to time.Time
+	var err error
+	if single {
+		if from, err = resolveDate(r.URL.Query().Get("date"), clock()); err != nil {
+			err = fmt.Errorf("invalid date: %v", err)
+		}
+		to = from
+	} else if from, err = weekMonday(r); err == nil {
+		to = from.AddDate(0, 0, 6)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return layout.Week{}, time.Time{}, false
+	}
+	days, err := buildDays(sess, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return layout.Week{}, time.Time{}, false
+	}
+	return weekLayout(sess, days, r.URL.Query().Get("personal") != "0"), from, true
+}
+
+// weekLayout converts day views of one week into the layout input. The
+// title names the student, the subtitle the class and the teaching week.
+func weekLayout(sess *Session, days []dayView, withExtras bool) layout.Week {
+	var wk layout.Week
+	name := displayName(sess.User.RealName, sess.User.NickName, sess.User.UserName, sess.UID)
+	wk.Title = name + " 的课程表"
+
+	var sub []string
+	if c := strings.TrimSpace(sess.User.ClassInfoName); c != "" {
+		sub = append(sub, c)
+	}
+	if len(days) > 0 {
+		if s := days[0].Semester; s != nil && s.SemesterName != "" {
+			sub = append(sub, s.SemesterName)
+		}
+		if n := days[0].TeachingWeek; n > 0 {
+			sub = append(sub, fmt.Sprintf("第 %d 周", n))
+		}
+		if len(days) == 1 {
+			sub = append(sub, days[0].Date)
+		} else {
+			sub = append(sub, days[0].Date+" ~ "+days[len(days)-1].Date)
+		}
+	}
+	wk.Subtitle = strings.Join(sub, " · ")
+
+	for _, d := range days {
+		i := d.WeekDay - 1
+		if i < 0 || i > 6 {
+			continue
+		}
+		day := layout.Day{Label: weekdayLabels[d.WeekDay%7], Date: d.Date[5:]}
+		var notes []string
+		if d.Calendar != nil {
+			notes = append(notes, d.Calendar.Label)
+		}
+		for _, it := range d.Schedule {
+			if it.Source != sourceCourse && !withExtras {
+				continue
+			}
+			if it.AllDay {
+				notes = append(notes, it.Title)
+				continue
+			}
+			begin, end := it.Begin.In(academicLoc), it.End.In(academicLoc)
+			e := layout.Entry{
+				Day:     i,
+				Start:   begin.Hour()*60 + begin.Minute(),
+				End:     end.Hour()*60 + end.Minute(),
+				Title:   it.Title,
+				Room:    it.Location,
+				Teacher: it.Teacher,
+				Key:     it.Title,
+				Muted:   it.Source != sourceCourse,
+			}
+			if it.Course != nil && it.Course.CourseID != "" {
+				e.Key = it.Course.CourseID
+			}
+			if end.YearDay() != begin.YearDay() {
+				e.End = 24 * 60
+			}
+			wk.Entries = append(wk.Entries, e)
+		}
+		day.Note = strings.Join(notes, "、")
+		wk.Days[i] = day
+	}
+	return wk
+}
+
+// handleCoursesWeekPDF serves the week as a printable PDF.
+// GET /courses/week.pdf?week=7 or ?date=<date expr>; ?personal=0, ?weekend=1 keeps empty weekend columns
+func handleCoursesWeekPDF(w http.ResponseWriter, r *http.Request) {
+	wk, monday, ok := timetableWeek(w, r, false)
+	if !ok {
+		return
+	}
+	doc := timetablePDF(wk, r.URL.Query().Get("weekend") == "1")
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="timetable-%s.pdf"`, monday.Format("20060102")))
+	if _, err := doc.WriteTo(w); err != nil {
+		log.Printf("write timetable pdf: %v", err)
+	}
+}
+
+// timetableCanvas is what paintTimetable draws on. Coordinates have their
+// origin at the top left; Text places the baseline at y.
+type timetableCanvas interface {
+	FillRect(x, y, w, h float64, c color.RGBA)
+	StrokeRect(x, y, w, h, width float64, c color.RGBA)
+	Line(x1, y1, x2, y2, width float64, c color.RGBA)
+	Text(x, y, size float64, c color.RGBA, s string)
+	TextWidth(s string, size float64) float64
+}
+
+// timetableStyle holds the colours and text sizes of one rendering.
+type timetableStyle struct {
+	Background, Ink, Faint, Rule, Header color.RGBA
+	Palette                              []color.RGBA
+	Title, Sub, Head, Note, Cell, Small  float64
+	Pad, Rules                           float64 // cell padding, rule width
+}
+
+var pdfStyle = timetableStyle{
+	Background: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	Ink:        color.RGBA{0x22, 0x22, 0x22, 0xFF},
+	Faint:      color.RGBA{0x66, 0x66, 0x66, 0xFF},
+	Rule:       color.RGBA{0xBB, 0xBB, 0xBB, 0xFF},
+	Header:     color.RGBA{0xEE, 0xEE, 0xEE, 0xFF},
+	Palette:    layout.DefaultPalette,
+	Title:      16, Sub: 9.5, Head: 10, Note: 7, Cell: 8, Small: 5.5,
+	Pad: 3, Rules: 0.4,
+}
+
+// pdfCanvas adapts a PDF page to timetableCanvas.
+type pdfCanvas struct{ *pdf.Page }
+
+func (pdfCanvas) TextWidth(s string, size float64) float64 { return pdf.TextWidth(s, size) }
+
+// timetablePDF paints wk on an A4 landscape page.
+func timetablePDF(wk layout.Week, keepWeekend bool) *pdf.Document {
+	doc := pdf.New(wk.Title)
+	page := doc.AddPage(pdf.A4Height, pdf.A4Width)
+	opt := layout.Options{
+		Width: page.Width, Height: page.Height, Margin: 28,
+		TitleHeight: 40, DayHeaderHeight: 30, PeriodWidth: 46,
+		Periods: timetablePeriods, KeepWeekend: keepWeekend,
+	}
+	paintTimetable(pdfCanvas{page}, wk, opt, pdfStyle)
+	return doc
+}
+
+// paintTimetable lays out wk with opt and draws it in style st.
+func paintTimetable(c timetableCanvas, wk layout.Week, opt layout.Options, st timetableStyle) {
+	opt.Palette = st.Palette
+	// Reserve room for entries outside every period before laying out.
+	if n := len(layout.Layout(wk, opt).Footnotes); n > 0 {
+		opt.FootnoteHeight = float64(n)*(st.Note+st.Note/4) + st.Note
+	}
+	g := layout.Layout(wk, opt)
+	measure := func(size float64) layout.Measure {
+		return func(s string) float64 { return c.TextWidth(s, size) }
+	}
+	centered := func(b layout.Box, y, size float64, col color.RGBA, s string) {
+		c.Text(b.X+(b.W-c.TextWidth(s, size))/2, y, size, col, s)
+	}
+
+	c.FillRect(0, 0, g.Width, g.Height, st.Background)
+	c.Text(g.Title.X, g.Title.Y+st.Title, st.Title, st.Ink, wk.Title)
+	c.Text(g.Title.X, g.Title.Y+st.Title+st.Sub*1.8, st.Sub, st.Faint, wk.Subtitle)
+
+	for _, col := range g.Columns {
+		c.FillRect(col.X, col.Y, col.W, col.H, st.Header)
+		// Narrow columns get the header in the note size, or only the weekday.
+		head, size := col.Label+" "+col.Date, st.Head
+		if c.TextWidth(head, size) > col.W-2*st.Pad {
+			size = st.Note
+		}
+		if c.TextWidth(head, size) > col.W-2*st.Pad {
+			head, size = col.Label, st.Head
+		}
+		centered(col.Box, col.Y+col.H/2+st.Head/2-st.Note/2, size, st.Ink, head)
+		if col.Note != "" {
+			if note := layout.Fit([]string{col.Note}, col.W-2*st.Pad, 1, measure(st.Note)); len(note) > 0 {
+				centered(col.Box, col.Y+col.H-st.Note/3, st.Note, st.Faint, note[0])
+			}
+		}
+	}
+	header := g.Columns[0].Box
+	c.FillRect(g.Body.X-opt.PeriodWidth, header.Y, opt.PeriodWidth, header.H, st.Header)
+	for _, row := range g.Rows {
+		mid := row.Y + row.H/2
+		centered(row.Box, mid, st.Head, st.Ink, row.Label)
+		centered(row.Box, mid+st.Small+st.Small/2, st.Small, st.Faint, layout.Clock(row.Start)+"-"+layout.Clock(row.End))
+	}
+
+	// Grid rules under the blocks, frame on top.
+	for _, row := range g.Rows[1:] {
+		c.Line(row.X, row.Y, g.Body.X+g.Body.W, row.Y, st.Rules, st.Rule)
+	}
+	for _, col := range g.Columns {
+		c.Line(col.X, col.Y, col.X, g.Body.Y+g.Body.H, st.Rules, st.Rule)
+	}
+	lineH := st.Cell + st.Cell/4
+	for _, b := range g.Blocks {
+		inset := st.Rules * 2
+		x, y, bw, bh := b.X+inset, b.Y+inset, b.W-2*inset, b.H-2*inset
+		fill, ink, faint := b.Fill, st.Ink, st.Faint
+		if b.Muted {
+			fill = layout.Blend(fill, st.Background, 0.5)
+			ink = faint
+		}
+		c.FillRect(x, y, bw, bh, fill)
+		maxLines := int(math.Floor((bh - 2*st.Pad + lineH - st.Cell) / lineH))
+		lines := layout.Fit([]string{b.Title, b.Room, b.Teacher}, bw-2*st.Pad, maxLines, measure(st.Cell))
+		for i, l := range lines {
+			col := ink
+			if i > 0 {
+				col = faint
+			}
+			c.Text(x+st.Pad, y+st.Pad+st.Cell+float64(i)*lineH, st.Cell, col, l)
+		}
+	}
+	c.Line(g.Body.X, header.Y+header.H, g.Body.X+g.Body.W, header.Y+header.H, st.Rules*1.5, st.Faint)
+	c.StrokeRect(g.Body.X-opt.PeriodWidth, header.Y, g.Body.W+opt.PeriodWidth, g.Body.Y+g.Body.H-header.Y, st.Rules*2, st.Faint)
+
+	for i, n := range g.Footnotes {
+		c.Text(g.Footer.X, g.Footer.Y+st.Note+float64(i+1)*(st.Note+st.Note/4), st.Note, st.Faint, "* "+n)
+	}
+}
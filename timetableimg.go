@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"LoginTest/bitfont"
+	"LoginTest/layout"
+	"LoginTest/textwidth"
+)
+
+// ------------------------------
+// Timetable images
+// ------------------------------
+// /courses/week.png and /courses/day.png draw the same grid as the PDF as
+// a picture for group chats. Text uses bitmap fonts so no rasteriser is
+// needed. The embedded 12 px font (package bitfont, cut from Ark Pixel and
+// Cubic 11) covers the common Chinese characters; TIMETABLE_FONT can put
+// BDF or GNU Unifont .hex files (optionally gzipped, comma separated,
+// tried in order) in front of it. ?theme= picks a colour theme, ?size= a
+// size preset.
+
+const timetableThemesFile = "timetable_themes.json"
+
+// imagePreset is a canvas size with the text height that reads well on it.
+type imagePreset struct {
+	Width, Height int
+	Text          float64 // target pixel height of cell text
+}
+
+var imagePresets = map[string]imagePreset{
+	"phone":       {1080, 1920, 28},
+	"phone-small": {750, 1334, 20},
+	"phone-large": {1290, 2796, 34},
+	"tablet":      {2048, 1536, 30},
+	"desktop":     {1920, 1080, 22},
+}
+
+// themeConfig is a theme as written in state/timetable_themes.json; colours
+// are "#RRGGBB". Missing fields fall back to the light theme.
+type themeConfig struct {
+	Background string   `json:"background"`
+	Ink        string   `json:"ink"`
+	Faint      string   `json:"faint"`
+	Rule       string   `json:"rule"`
+	Header     string   `json:"header"`
+	Palette    []string `json:"palette"`
+}
+
+var (
+	timetableFonts  []*bitfont.Font
+	timetableThemes = map[string]timetableStyle{
+		"light": pdfStyle,
+		"dark": {
+			Background: color.RGBA{0x1E, 0x1F, 0x22, 0xFF},
+			Ink:        color.RGBA{0xEE, 0xEE, 0xEE, 0xFF},
+			Faint:      color.RGBA{0xA8, 0xA8, 0xA8, 0xFF},
+			Rule:       color.RGBA{0x3A, 0x3C, 0x40, 0xFF},
+			Header:     color.RGBA{0x2B, 0x2D, 0x31, 0xFF},
+			Palette:    blendPalette(layout.DefaultPalette, color.RGBA{0x1E, 0x1F, 0x22, 0xFF}, 0.7),
+		},
+		"mono": {
+			Background: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+			Ink:        color.RGBA{0x00, 0x00, 0x00, 0xFF},
+			Faint:      color.RGBA{0x55, 0x55, 0x55, 0xFF},
+			Rule:       color.RGBA{0x99, 0x99, 0x99, 0xFF},
+			Header:     color.RGBA{0xE0, 0xE0, 0xE0, 0xFF},
+			Palette:    []color.RGBA{{0xF2, 0xF2, 0xF2, 0xFF}, {0xE6, 0xE6, 0xE6, 0xFF}, {0xD9, 0xD9, 0xD9, 0xFF}},
+		},
+	}
+)
+
+func blendPalette(p []color.RGBA, to color.RGBA, t float64) []color.RGBA {
+	out := make([]color.RGBA, len(p))
+	for i, c := range p {
+		out[i] = layout.Blend(c, to, t)
+	}
+	return out
+}
+
+// loadTimetableImages reads TIMETABLE_FONT and state/timetable_themes.json.
+// Configured fonts come first, then the embedded CJK font and the basic
+// ASCII font as fallbacks.
+func loadTimetableImages() {
+	var fonts []*bitfont.Font
+	if v := os.Getenv("TIMETABLE_FONT"); v != "" {
+		for _, path := range strings.Split(v, ",") {
+			if path = strings.TrimSpace(path); path == "" {
+				continue
+			}
+			f, err := bitfont.Open(path)
+			if err != nil {
+				log.Printf("load timetable font failed: %v", err)
+				continue
+			}
+			log.Printf("timetable font %s: %d glyphs", path, f.Glyphs())
+			fonts = append(fonts, f)
+		}
+	}
+	timetableFonts = append(fonts, bitfont.CJK(), bitfont.Basic())
+
+	var custom map[string]themeConfig
+	if err := loadDataFile(timetableThemesFile, &custom); err != nil {
+		log.Printf("load timetable themes failed: %v", err)
+	}
+	for name, cfg := range custom {
+		st, err := cfg.style()
+		if err != nil {
+			log.Printf("timetable theme %q: %v", name, err)
+			continue
+		}
+		timetableThemes[name] = st
+	}
+}
+
+func (cfg themeConfig) style() (timetableStyle, error) {
+	st := timetableThemes["light"]
+	for _, f := range []struct {
+		s   string
+		dst *color.RGBA
+	}{
+		{cfg.Background, &st.Background}, {cfg.Ink, &st.Ink}, {cfg.Faint, &st.Faint},
+		{cfg.Rule, &st.Rule}, {cfg.Header, &st.Header},
+	} {
+		if f.s == "" {
+			continue
+		}
+		c, err := parseHexColor(f.s)
+		if err != nil {
+			return st, err
+		}
+		*f.dst = c
+	}
+	if len(cfg.Palette) > 0 {
+		st.Palette = nil
+		for _, s := range cfg.Palette {
+			c, err := parseHexColor(s)
+			if err != nil {
+				return st, err
+			}
+			st.Palette = append(st.Palette, c)
+		}
+	}
+	return st, nil
+}
+
+func parseHexColor(s string) (color.RGBA, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(s), "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(strings.TrimSpace(s), "#")) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q (want #RRGGBB)", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xFF}, nil
+}
+
+// imageCanvas draws on an RGBA image. Text sizes are rounded to integer
+// multiples of the font's pixel height; lines are axis-aligned only.
+type imageCanvas struct {
+	img   *image.RGBA
+	fonts []*bitfont.Font
+}
+
+func (c imageCanvas) face(size float64) bitfont.Face {
+	return bitfont.Face{Fonts: c.fonts, Scale: max(1, int(math.Round(size/float64(c.fonts[0].Height))))}
+}
+
+func (c imageCanvas) FillRect(x, y, w, h float64, col color.RGBA) {
+	r := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+	draw.Draw(c.img, r, image.NewUniform(col), image.Point{}, draw.Src)
+}
+
+func (c imageCanvas) StrokeRect(x, y, w, h, width float64, col color.RGBA) {
+	c.Line(x, y, x+w, y, width, col)
+	c.Line(x, y+h, x+w, y+h, width, col)
+	c.Line(x, y, x, y+h, width, col)
+	c.Line(x+w, y, x+w, y+h, width, col)
+}
+
+func (c imageCanvas) Line(x1, y1, x2, y2, width float64, col color.RGBA) {
+	width = math.Max(1, math.Round(width))
+	c.FillRect(math.Min(x1, x2)-width/2, math.Min(y1, y2)-width/2, math.Abs(x2-x1)+width, math.Abs(y2-y1)+width, col)
+}
+
+func (c imageCanvas) Text(x, y, size float64, col color.RGBA, s string) {
+	c.face(size).Draw(c.img, int(math.Round(x)), int(math.Round(y)), s, col, textwidth.IsWide)
+}
+
+func (c imageCanvas) TextWidth(s string, size float64) float64 {
+	return float64(c.face(size).Width(s, textwidth.IsWide))
+}
+
+// handleCoursesWeekPNG serves the week as an image.
+// GET /courses/week.png?week=7 or ?date=<date expr>; ?theme=light|dark|mono|<custom>,
+// ?size=phone|phone-small|phone-large|tablet|desktop, ?personal=0, ?weekend=1
+func handleCoursesWeekPNG(w http.ResponseWriter, r *http.Request) {
+	serveTimetablePNG(w, r, false)
+}
+
+// handleCoursesDayPNG serves one day as an image.
+// GET /courses/day.png?date=<date expr> (default today); ?theme=, ?size=, ?personal=0 as for week.png
+func handleCoursesDayPNG(w http.ResponseWriter, r *http.Request) {
+	serveTimetablePNG(w, r, true)
+}
+
+func serveTimetablePNG(w http.ResponseWriter, r *http.Request, single bool) {
+	q := r.URL.Query()
+	theme := strings.ToLower(strings.TrimSpace(q.Get("theme")))
+	if theme == "" {
+		theme = "light"
+	}
+	st, ok := timetableThemes[theme]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown theme %q (have %s)", theme, strings.Join(sortedKeys(timetableThemes), ", ")), http.StatusBadRequest)
+		return
+	}
+	size := strings.ToLower(strings.TrimSpace(q.Get("size")))
+	if size == "" {
+		size = "phone"
+	}
+	preset, ok := imagePresets[size]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown size %q (have %s)", size, strings.Join(sortedKeys(imagePresets), ", ")), http.StatusBadRequest)
+		return
+	}
+
+	wk, first, ok := timetableWeek(w, r, single)
+	if !ok {
+		return
+	}
+	opt := layout.Options{Periods: timetablePeriods, KeepWeekend: q.Get("weekend") == "1"}
+	name := "week"
+	if single {
+		opt.Days = []int{daysFromMonday(first.Weekday())}
+		name = "day"
+	}
+	img := timetableImage(wk, opt, st, preset)
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="timetable-%s-%s.png"`, name, first.Format("20060102")))
+	if err := png.Encode(w, img); err != nil {
+		log.Printf("write timetable png: %v", err)
+	}
+}
+
+// timetableImage paints wk at the preset's size. Text sizes are whole
+// multiples of the font height so bitmap glyphs scale without blur.
+func timetableImage(wk layout.Week, opt layout.Options, st timetableStyle, p imagePreset) *image.RGBA {
+	c := imageCanvas{img: image.NewRGBA(image.Rect(0, 0, p.Width, p.Height)), fonts: timetableFonts}
+	unit := float64(c.fonts[0].Height)
+	scale := max(1, math.Round(p.Text/unit))
+	st.Cell = unit * scale
+	st.Head = st.Cell
+	st.Title = unit * (scale + 1)
+	st.Sub = unit * max(1, scale-1)
+	st.Note = st.Sub
+	st.Small = st.Sub
+	st.Pad = math.Round(st.Cell / 4)
+	st.Rules = math.Max(1, math.Round(st.Cell/16))
+
+	opt.Width, opt.Height = float64(p.Width), float64(p.Height)
+	opt.Margin = st.Cell
+	opt.TitleHeight = st.Title + st.Sub*1.8 + st.Cell
+	opt.DayHeaderHeight = st.Head + st.Note + 3*st.Pad
+	opt.PeriodWidth = c.TextWidth("00:00-00:00", st.Small) + 2*st.Pad
+	paintTimetable(c, wk, opt, st)
+	return c.img
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"LoginTest/auth"
+)
+
+// ------------------------------
+// Server-rendered UI
+// ------------------------------
+// /ui/ is a plain HTML front end for machines where the JavaScript one in
+// web/ cannot run (locked-down lab PCs, text browsers such as w3m or
+// lynx). It uses the same session cookie and the same course, week and
+// login code as the JSON API; pages are html/template with no scripts and
+// only a few lines of inline CSS.
+//
+//	GET  /ui/login        login form      POST /ui/login   log in, then to /ui/
+//	GET  /ui/?date=expr   one day         GET  /ui/week?week=7|date=expr
+//	POST /ui/logout       log out
+
+// uiPage feeds every UI template.
+type uiPage struct {
+	Title string
+	User  string // display name; empty when logged out
+	Class string
+	Error string
+	Phone string // login form refill
+
+	Heading   string
+	DateInput string
+	Days      []dayView
+	Unit      string // 天 or 周, for the navigation links
+	Prev      string // links of the previous/next day or week
+	Next      string
+	Today     string
+	TodayText string
+	Exports   []uiLink
+}
+
+type uiLink struct{ Label, URL string }
+
+var uiFuncs = template.FuncMap{
+	"clock":   clockOf,
+	"source":  func(s string) string { return sourceLabels[s] },
+	"weekday": func(d dayView) string { return weekdayLabels[d.WeekDay%7] },
+}
+
+var uiBase = template.Must(template.New("page").Funcs(uiFuncs).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>{{.Title}} - 课程签到助手</title>
+<style>
+body{font-family:sans-serif;max-width:48em;margin:0 auto;padding:0 1em;line-height:1.5;color:#222}
+header{border-bottom:1px solid #ccc;padding:.5em 0}
+header form{display:inline}
+table{border-collapse:collapse;width:100%}
+th,td{text-align:left;padding:.3em .5em;border-bottom:1px solid #eee;vertical-align:top}
+tr.personal,tr.calendar{color:#666}
+.note{color:#b45309}
+.warn{color:#b91c1c}
+.error{color:#b91c1c;font-weight:bold}
+nav{margin:.5em 0}
+</style>
+</head>
+<body>
+{{if .User}}<header>
+<strong>课程签到助手</strong> |
+<a href="/ui/">今日课程</a> |
+<a href="/ui/week">本周课表</a> |
+{{.User}}{{if .Class}}（{{.Class}}）{{end}}
+<form method="post" action="/ui/logout"><button type="submit">退出登录</button></form>
+</header>{{end}}
+<main>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{template "content" .}}
+</main>
+</body>
+</html>
+{{define "day"}}<section>
+<h2>{{.Date}} {{weekday .}}{{if .TeachingWeek}} · 第 {{.TeachingWeek}} 周{{end}}</h2>
+{{with .Calendar}}<p class="note">{{.Label}}</p>{{end}}
+{{if .Schedule}}<table>
+<thead><tr><th>时间</th><th>课程/日程</th><th>地点</th><th>教师</th></tr></thead>
+<tbody>
+{{range .Schedule}}<tr class="{{.Source}}">
+<td>{{if .AllDay}}全天{{else}}{{clock .Begin}}-{{clock .End}}{{end}}</td>
+<td>{{.Title}}{{if ne .Source "course"}} <small>（{{source .Source}}）</small>{{end}}</td>
+<td>{{.Location}}</td>
+<td>{{.Teacher}}</td>
+</tr>
+{{end}}</tbody>
+</table>
+{{range .Transitions}}{{if .Warning}}<p class="warn">注意：{{.Warning}}</p>{{end}}{{end}}
+{{else}}<p>没有课程。</p>{{end}}
+</section>{{end}}
+{{define "nav"}}<nav>
+<a href="{{.Prev}}">&laquo; 上一{{.Unit}}</a> |
+<a href="{{.Today}}">{{.TodayText}}</a> |
+<a href="{{.Next}}">下一{{.Unit}} &raquo;</a>
+</nav>
+<form method="get">
+<label>日期 <input type="text" name="date" value="{{.DateInput}}" size="12" placeholder="tomorrow"></label>
+<button type="submit">查看</button>
+</form>{{end}}
+`))
+
+func uiTemplate(content string) *template.Template {
+	return template.Must(template.Must(uiBase.Clone()).Parse(`{{define "content"}}` + content + `{{end}}`))
+}
+
+var (
+	uiLoginTmpl = uiTemplate(`<h1>登录</h1>
+<form method="post" action="/ui/login">
+<p><label>学号<br><input type="text" name="phone" value="{{.Phone}}" required autocomplete="username"></label></p>
+<p><label>密码<br><input type="password" name="password" required autocomplete="current-password"></label></p>
+<p><button type="submit">登 录</button></p>
+</form>`)
+
+	uiDayTmpl = uiTemplate(`<h1>{{.Heading}}</h1>
+{{template "nav" .}}
+{{range .Days}}{{template "day" .}}{{end}}`)
+
+	uiWeekTmpl = uiTemplate(`<h1>{{.Heading}}</h1>
+{{template "nav" .}}
+{{if .Exports}}<p>导出：{{range $i, $l := .Exports}}{{if $i}} | {{end}}<a href="{{$l.URL}}">{{$l.Label}}</a>{{end}}</p>{{end}}
+{{range .Days}}{{template "day" .}}{{end}}`)
+)
+
+// renderUI writes one page; pages carry personal data, so nothing is cached.
+func renderUI(w http.ResponseWriter, status int, t *template.Template, p uiPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := t.Execute(w, p); err != nil {
+		log.Printf("render ui %s: %v", p.Title, err)
+	}
+}
+
+// uiSession returns the session of r, sending the browser to the login
+// form when there is none.
+func uiSession(w http.ResponseWriter, r *http.Request) (*Session, uiPage, bool) {
+	sess, sid, ok := getSession(r)
+	if !ok {
+		http.Redirect(w, r, "/ui/login", http.StatusSeeOther)
+		return nil, uiPage{}, false
+	}
+	touchSession(sid)
+	return sess, uiPage{
+		User:  displayName(sess.User.RealName, sess.User.NickName, sess.User.UserName, sess.UID),
+		Class: strings.TrimSpace(sess.User.ClassInfoName),
+	}, true
+}
+
+// handleUILogin shows the login form and logs in with it.
+func handleUILogin(w http.ResponseWriter, r *http.Request) {
+	page := uiPage{Title: "登录"}
+	switch r.Method {
+	case http.MethodGet:
+		if _, _, ok := getSession(r); ok {
+			http.Redirect(w, r, "/ui/", http.StatusSeeOther)
+			return
+		}
+		renderUI(w, http.StatusOK, uiLoginTmpl, page)
+	case http.MethodPost:
+		page.Phone = strings.TrimSpace(r.PostFormValue("phone"))
+		sid, _, lerr := login(auth.LoginParams{Phone: page.Phone, Password: r.PostFormValue("password")})
+		if lerr != nil {
+			page.Error = uiLoginError(lerr)
+			status := lerr.Status
+			if status < http.StatusBadRequest {
+				status = http.StatusBadGateway
+			}
+			renderUI(w, status, uiLoginTmpl, page)
+			return
+		}
+		setSessionCookie(w, sid)
+		http.Redirect(w, r, "/ui/", http.StatusSeeOther)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func uiLoginError(e *loginError) string {
+	switch {
+	case e.Status == http.StatusBadRequest:
+		return "请输入学号和密码。"
+	case e.Raw != nil || strings.Contains(e.Message, "empty user id"):
+		return "登录失败，请检查学号和密码。"
+	}
+	return "登录失败：" + e.Message
+}
+
+// handleUILogout ends the session and returns to the login form.
+func handleUILogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	clearSession(w, r)
+	http.Redirect(w, r, "/ui/login", http.StatusSeeOther)
+}
+
+// handleUIDay shows one day, today unless ?date= says otherwise.
+func handleUIDay(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/ui/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, page, ok := uiSession(w, r)
+	if !ok {
+		return
+	}
+	page.Title = "今日课程"
+	page.Heading = page.Title
+	page.DateInput = r.URL.Query().Get("date")
+	page.Unit, page.Today, page.TodayText = "天", "/ui/", "今天"
+	day, err := resolveDate(page.DateInput, clock())
+	if err != nil {
+		page.Error = "无法识别的日期：" + err.Error()
+		renderUI(w, http.StatusBadRequest, uiDayTmpl, page)
+		return
+	}
+	page.Heading = day.Format("2006-01-02") + " 课程"
+	page.Prev = "/ui/?date=" + day.AddDate(0, 0, -1).Format("20060102")
+	page.Next = "/ui/?date=" + day.AddDate(0, 0, 1).Format("20060102")
+	days, err := buildDays(sess, day, day)
+	if err != nil {
+		page.Error = "获取课表失败：" + err.Error()
+		renderUI(w, http.StatusBadGateway, uiDayTmpl, page)
+		return
+	}
+	page.Days = days
+	renderUI(w, http.StatusOK, uiDayTmpl, page)
+}
+
+// handleUIWeek shows Monday..Sunday; ?week= and ?date= as for /courses/week.
+func handleUIWeek(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess, page, ok := uiSession(w, r)
+	if !ok {
+		return
+	}
+	page.Title = "本周课表"
+	page.Heading = page.Title
+	page.DateInput = r.URL.Query().Get("date")
+	page.Unit, page.Today, page.TodayText = "周", "/ui/week", "本周"
+	monday, err := weekMonday(r)
+	if err != nil {
+		page.Error = err.Error()
+		renderUI(w, http.StatusBadRequest, uiWeekTmpl, page)
+		return
+	}
+	sunday := monday.AddDate(0, 0, 6)
+	page.Heading = monday.Format("2006-01-02") + " ~ " + sunday.Format("2006-01-02")
+	page.Prev = "/ui/week?date=" + monday.AddDate(0, 0, -7).Format("20060102")
+	page.Next = "/ui/week?date=" + monday.AddDate(0, 0, 7).Format("20060102")
+	days, err := buildDays(sess, monday, sunday)
+	if err != nil {
+		page.Error = "获取课表失败：" + err.Error()
+		renderUI(w, http.StatusBadGateway, uiWeekTmpl, page)
+		return
+	}
+	if n := days[0].TeachingWeek; n > 0 {
+		page.Heading = fmt.Sprintf("第 %d 周（%s）", n, page.Heading)
+	}
+	// Empty weekend days are left out.
+	for _, d := range days {
+		if d.WeekDay >= 6 && len(d.Schedule) == 0 && d.Calendar == nil {
+			continue
+		}
+		page.Days = append(page.Days, d)
+	}
+	q := "?date=" + monday.Format("20060102")
+	page.Exports = []uiLink{
+		{"PDF", apiV1 + "/courses/week.pdf" + q},
+		{"图片", apiV1 + "/courses/week.png" + q},
+		{"日历（ICS）", apiV1 + "/courses/calendar.ics?from=" + monday.Format("20060102") + "&to=" + sunday.Format("20060102")},
+		{"CSV", apiV1 + "/courses/week" + q + "&format=csv"},
+	}
+	renderUI(w, http.StatusOK, uiWeekTmpl, page)
+}
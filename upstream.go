@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+
+	"LoginTest/lenient"
+)
+
+// ------------------------------
+// Tolerant decoding of upstream JSON
+// ------------------------------
+// The iclass API is not strict about types: numbers show up where strings
+// are expected, nulls and arrays in odd places. decodeUpstream maps such
+// drift onto our structs instead of failing, and counts it in expvar so it
+// is visible on /debug/vars:
+//   upstream_coercions     "<endpoint>:<path>:<from>-><to>" -> count
+//   upstream_unknown_fields "<endpoint>:<path>"             -> count
+
+var (
+	upstreamCoercions = expvar.NewMap("upstream_coercions")
+	upstreamUnknown   = expvar.NewMap("upstream_unknown_fields")
+)
+
+// decodeUpstream decodes an upstream response body of endpoint ("login",
+// "schedule", ...), answered with HTTP status, into v. It only fails when
+// the body is not a JSON object.
+func decodeUpstream(endpoint string, status int, body []byte, v any) error {
+	observeSchema(endpoint, status, body)
+	rep, err := lenient.Decode(body, v)
+	if err != nil {
+		return err
+	}
+	for _, c := range rep.Coercions {
+		upstreamCoercions.Add(endpoint+":"+c.Path+":"+c.From+"->"+c.To, 1)
+	}
+	for _, path := range rep.Unknown {
+		upstreamUnknown.Add(endpoint+":"+path, 1)
+	}
+	if len(rep.Coercions) > 0 {
+		log.Printf("upstream %s: coerced %d field(s), first %s (%s -> %s)",
+			endpoint, len(rep.Coercions), rep.Coercions[0].Path, rep.Coercions[0].From, rep.Coercions[0].To)
+	}
+	return nil
+}
+
+// upstreamSucceeded reports whether an upstream reply is a success: HTTP
+// 200 and STATUS "0" (a string or, after drift, a number).
+func upstreamSucceeded(status int, body []byte) bool {
+	if status != http.StatusOK {
+		return false
+	}
+	var head struct {
+		STATUS any `json:"STATUS"`
+	}
+	if err := json.Unmarshal(body, &head); err != nil || head.STATUS == nil {
+		return false
+	}
+	return fmt.Sprint(head.STATUS) == "0"
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"LoginTest/geo"
+	"LoginTest/models"
+)
+
+// ------------------------------
+// Walking time between classes
+// ------------------------------
+// For back-to-back entries the day view estimates the walk from one room
+// to the next: haversine distance times a detour factor, at WALKING_SPEED
+// km/h (default 4.5). Coordinates come from the upstream classroom fields;
+// where those are missing the campus building table in state/buildings.json
+// fills in, matched by TeachBuildName (or the start of a personal
+// event's location):
+//
+//	[{"name":"教一楼","aliases":["第一教学楼"],"latitude":40.4081,"longitude":116.6797}]
+
+const (
+	buildingsFile       = "buildings.json"
+	defaultWalkingSpeed = 4.5 // km/h
+	// Paths are longer than the straight line between two buildings.
+	walkDetourFactor = 1.3
+	// Entries further apart than this are not a transition.
+	maxTransitionGap = 30 * time.Minute
+	// A walk that leaves less than this before the next entry is "tight".
+	tightMargin = 3 * time.Minute
+)
+
+// Building is one row of the campus building table.
+type Building struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	geo.Point
+}
+
+// transition describes the move between two consecutive entries.
+type transition struct {
+	FromID         string `json:"fromId"`
+	ToID           string `json:"toId"`
+	From           string `json:"from"`
+	To             string `json:"to"`
+	FromBuilding   string `json:"fromBuilding,omitempty"`
+	ToBuilding     string `json:"toBuilding,omitempty"`
+	GapMinutes     int    `json:"gapMinutes"`
+	DistanceMeters int    `json:"distanceMeters,omitempty"`
+	WalkMinutes    int    `json:"walkMinutes,omitempty"`
+	Status         string `json:"status"` // ok, tight, late or unknown (no coordinates)
+	Warning        string `json:"warning,omitempty"`
+}
+
+var (
+	buildings    []Building
+	buildingsMu  sync.RWMutex
+	walkingSpeed = defaultWalkingSpeed
+)
+
+// loadWalkingConfig reads WALKING_SPEED and state/buildings.json.
+func loadWalkingConfig() {
+	if v := os.Getenv("WALKING_SPEED"); v != "" {
+		if s, err := strconv.ParseFloat(v, 64); err == nil && s > 0 {
+			walkingSpeed = s
+		} else {
+			log.Printf("ignoring invalid WALKING_SPEED %q", v)
+		}
+	}
+	buildingsMu.Lock()
+	defer buildingsMu.Unlock()
+	if err := loadDataFile(buildingsFile, &buildings); err != nil {
+		log.Printf("load buildings failed: %v", err)
+	}
+	for _, b := range buildings {
+		if !b.Point.Valid() {
+			log.Printf("building %q has invalid coordinates", b.Name)
+		}
+	}
+}
+
+// lookupBuilding finds the table row named (or aliased) name; for free
+// text such as a personal event location the longest name it starts with
+// wins.
+func lookupBuilding(name string) (Building, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Building{}, false
+	}
+	buildingsMu.RLock()
+	defer buildingsMu.RUnlock()
+	var best Building
+	bestLen := 0
+	for _, b := range buildings {
+		for _, n := range append([]string{b.Name}, b.Aliases...) {
+			if n == "" || !strings.HasPrefix(name, n) || len(n) <= bestLen {
+				continue
+			}
+			best, bestLen = b, len(n)
+		}
+	}
+	return best, bestLen > 0 && best.Point.Valid()
+}
+
+// itemPlace returns the building name and coordinates of an entry.
+func itemPlace(it scheduleItem) (string, geo.Point, bool) {
+	if it.Course != nil {
+		name := it.Course.TeachBuildName
+		c, _ := models.NormalizeCourse(*it.Course)
+		if c.Latitude != nil && c.Longitude != nil {
+			if p := (geo.Point{Lat: *c.Latitude, Lon: *c.Longitude}); p.Valid() {
+				return name, p, true
+			}
+		}
+		if b, ok := lookupBuilding(name); ok {
+			return name, b.Point, true
+		}
+		return name, geo.Point{}, false
+	}
+	if b, ok := lookupBuilding(it.Location); ok {
+		return b.Name, b.Point, true
+	}
+	return "", geo.Point{}, false
+}
+
+// transitions lists the walks between consecutive timed entries of one
+// day's schedule that are at most maxTransitionGap apart.
+func transitions(items []scheduleItem) []transition {
+	var timed []scheduleItem
+	for _, it := range items {
+		if !it.AllDay && it.End.After(it.Begin) {
+			timed = append(timed, it)
+		}
+	}
+	var out []transition
+	for i := 0; i+1 < len(timed); i++ {
+		a, b := timed[i], timed[i+1]
+		gap := b.Begin.Sub(a.End)
+		if gap < 0 || gap > maxTransitionGap {
+			// Overlaps are reported by /schedule/conflicts.
+			continue
+		}
+		t := transition{
+			FromID:     a.ID,
+			ToID:       b.ID,
+			From:       a.Title,
+			To:         b.Title,
+			GapMinutes: int(gap / time.Minute),
+			Status:     "unknown",
+		}
+		fromName, p1, ok1 := itemPlace(a)
+		toName, p2, ok2 := itemPlace(b)
+		t.FromBuilding, t.ToBuilding = fromName, toName
+		switch {
+		case fromName != "" && fromName == toName:
+			t.Status = "ok"
+		case ok1 && ok2:
+			meters := geo.Distance(p1, p2) * walkDetourFactor
+			walk := time.Duration(meters / (walkingSpeed * 1000 / 60) * float64(time.Minute))
+			t.DistanceMeters = int(math.Round(meters))
+			t.WalkMinutes = int(math.Ceil(walk.Minutes()))
+			switch {
+			case walk > gap:
+				t.Status = "late"
+			case walk > gap-tightMargin:
+				t.Status = "tight"
+			default:
+				t.Status = "ok"
+			}
+		}
+		t.Warning = t.warning()
+		out = append(out, t)
+	}
+	return out
+}
+
+func (t transition) warning() string {
+	switch t.Status {
+	case "late":
+		return fmt.Sprintf("课间 %d 分钟，从%s步行到%s约需 %d 分钟（约 %d 米），可能迟到",
+			t.GapMinutes, t.FromBuilding, t.ToBuilding, t.WalkMinutes, t.DistanceMeters)
+	case "tight":
+		return fmt.Sprintf("课间 %d 分钟，步行约需 %d 分钟，时间较紧", t.GapMinutes, t.WalkMinutes)
+	}
+	return ""
+}
+
+// addTransitions adds "transitions" for the "schedule" of a day payload.
+func addTransitions(payload map[string]any) {
+	if items, ok := payload["schedule"].([]scheduleItem); ok {
+		if ts := transitions(items); len(ts) > 0 {
+			payload["transitions"] = ts
+		}
+	}
+}
@@ -28,6 +28,7 @@ function pickRowId(course) {
 }
 
 function showLoginForm() {
+    disconnectEvents();
     document.getElementById("loginView").classList.add("active");
     document.getElementById("dashboardView").classList.remove("active");
 }
@@ -38,6 +39,7 @@ function showCourseContainer() {
     // Update date badge
     const d = new Date();
     document.getElementById("currentDate").textContent = `${d.getFullYear()}-${String(d.getMonth() + 1).padStart(2, '0')}-${String(d.getDate()).padStart(2, '0')}`;
+    connectEvents();
 }
 
 function formatTime(timeStr) {
@@ -89,7 +91,7 @@ async function login() {
     btn.classList.add("button-loading");
 
     try {
-        const res = await fetch('/login', {
+        const res = await fetch('/api/v1/login', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({
@@ -128,7 +130,7 @@ async function logout() {
     if (!confirm('确定要退出登录吗？')) return;
 
     try {
-        await fetch('/logout', { method: 'POST' });
+        await fetch('/api/v1/logout', { method: 'POST' });
     } catch (e) {
         console.error('Logout error:', e);
     }
@@ -145,7 +147,7 @@ async function logout() {
 // ========================================
 window.onload = async function() {
     try {
-        const res = await fetch('/me', { method: 'GET' });
+        const res = await fetch('/api/v1/me', { method: 'GET' });
         if (res.ok) {
             const data = await res.json();
             currentUser = data.user; // Store user info
@@ -181,7 +183,7 @@ async function fetchCourses(force = false) {
     const dateStr = todayStr();
 
     try {
-        const res = await fetch('/courses/today', {
+        const res = await fetch('/api/v1/courses/today', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({ dateStr })
@@ -300,6 +302,47 @@ function createCourseCard(course) {
     return card;
 }
 
+// ========================================
+// Live Updates (SSE)
+// ========================================
+let eventSource = null;
+
+function connectEvents() {
+    if (eventSource || !window.EventSource) return;
+    // EventSource reconnects on its own and sends Last-Event-ID to resume.
+    eventSource = new EventSource('/api/v1/events');
+
+    eventSource.addEventListener('courses.fetched', (e) => {
+        const data = JSON.parse(e.data);
+        if (data.date !== todayStr()) return;
+        const count = renderCourses(data.result || []);
+        document.getElementById('courseMessage').textContent = count === 0 ? '今日暂无课程' : '';
+    });
+    eventSource.addEventListener('courses.changed', (e) => {
+        const change = JSON.parse(e.data);
+        showToast(`课程变更：${change.courseName}`, 'info');
+    });
+    eventSource.addEventListener('schedule.conflict', (e) => {
+        const conflict = JSON.parse(e.data);
+        showToast(`日程冲突：${conflict.first.title} / ${conflict.second.title}`, 'error');
+    });
+    eventSource.addEventListener('resync', () => {
+        fetchCourses(true);
+    });
+    eventSource.addEventListener('session.expired', () => {
+        currentUser = null;
+        showLoginForm();
+        showToast('登录已过期，请重新登录', 'error');
+    });
+}
+
+function disconnectEvents() {
+    if (eventSource) {
+        eventSource.close();
+        eventSource = null;
+    }
+}
+
 // ========================================
 // Sign Course
 // ========================================
@@ -315,7 +358,7 @@ async function signCourse(timeTableId) {
     try {
         // 添加随机偏移
         const timestamp = Date.now() + 1000 * timeDelta - Math.floor(2000 * Math.random() + 1000);
-        const res = await fetch('/api/sign-in', {
+        const res = await fetch('/api/v1/sign-in', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({ timeTableId, timestamp })
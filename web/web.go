@@ -0,0 +1,12 @@
+// Package web holds the browser front end (index.html, main.css, main.js).
+// The files are embedded into the binary, together with any precompressed
+// .gz variants present at build time; the main package serves them.
+package web
+
+import "embed"
+
+// FS is the embedded directory; it also contains this source file, which
+// is never served.
+//
+//go:embed *
+var FS embed.FS